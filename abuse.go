@@ -0,0 +1,181 @@
+package gosnake
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	addrPacketRate     = 100
+	addrPacketBurst    = 200
+	addrJoinRate       = 0.5
+	addrJoinBurst      = 10
+	addrMalformedRate  = 1
+	addrMalformedBurst = 20
+	addrBanDuration    = time.Minute
+	addrIdleExpiry     = time.Minute
+
+	// the rehandshakes are replied at the rate of the handshakes of the
+	// client, see rehandshakeInterval
+	addrRehandshakeRate  = 0.5
+	addrRehandshakeBurst = 2
+
+	playerCMDRate  = 30
+	playerCMDBurst = 60
+)
+
+// AbuseStats counts the packets refused by the server, RateLimited counts
+// the packets over the rate of their address, JoinLimited counts the
+// joins and room creations over the rate of their address, Banned counts
+// the bans and BannedPackets the packets of the banned addresses, Dropped
+// counts the packets dropped since their room is busy, PlayerLimited
+// counts the commands over the rate of their player.
+type AbuseStats struct {
+	RateLimited   uint64 `json:"rate_limited"`
+	JoinLimited   uint64 `json:"join_limited"`
+	Malformed     uint64 `json:"malformed"`
+	Banned        uint64 `json:"banned"`
+	BannedPackets uint64 `json:"banned_packets"`
+	Dropped       uint64 `json:"dropped"`
+	PlayerLimited uint64 `json:"player_limited"`
+}
+
+type addrGuard struct {
+	packets      *TokenBucket
+	joins        *TokenBucket
+	malformed    *TokenBucket
+	rehandshakes *TokenBucket
+	bannedUntil  time.Time
+	lastSeen     time.Time
+}
+
+// AbuseGuard limits the packets per source address before they are
+// decoded, the addresses sending malformed packets repeatedly are banned
+// for a while. The addresses are keyed by IP since the ports are free to
+// choose. It is safe to use from multiple goroutines.
+type AbuseGuard struct {
+	mu        sync.Mutex
+	addrs     map[string]*addrGuard
+	lastSweep time.Time
+	stats     AbuseStats
+}
+
+func NewAbuseGuard() *AbuseGuard {
+	return &AbuseGuard{
+		addrs: make(map[string]*addrGuard),
+	}
+}
+
+// Allow reports whether the packet of the address should be handled.
+func (ag *AbuseGuard) Allow(addr *net.UDPAddr, now time.Time) bool {
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+	ag.sweep(now)
+	guard := ag.getAddr(addr, now)
+	if now.Before(guard.bannedUntil) {
+		atomic.AddUint64(&ag.stats.BannedPackets, 1)
+		return false
+	}
+	if !guard.packets.Allow(now, 1) {
+		atomic.AddUint64(&ag.stats.RateLimited, 1)
+		return false
+	}
+	return true
+}
+
+// AllowJoin reports whether the address may join or create a room, it
+// caps the players and rooms created by the address.
+func (ag *AbuseGuard) AllowJoin(addr *net.UDPAddr, now time.Time) bool {
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+	if !ag.getAddr(addr, now).joins.Allow(now, 1) {
+		atomic.AddUint64(&ag.stats.JoinLimited, 1)
+		return false
+	}
+	return true
+}
+
+// AllowRehandshake reports whether the sealed packet of the address
+// without a session should be replied with the rehandshake marker.
+func (ag *AbuseGuard) AllowRehandshake(addr *net.UDPAddr, now time.Time) bool {
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+	return ag.getAddr(addr, now).rehandshakes.Allow(now, 1)
+}
+
+// Malformed records a packet of the address which could not be opened or
+// decoded, the address is banned once it sends them faster than the rate.
+func (ag *AbuseGuard) Malformed(addr *net.UDPAddr, now time.Time) {
+	atomic.AddUint64(&ag.stats.Malformed, 1)
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+	guard := ag.getAddr(addr, now)
+	if guard.malformed.Allow(now, 1) {
+		return
+	}
+	ag.ban(addr, guard, now, addrBanDuration)
+	guard.malformed = NewTokenBucket(addrMalformedRate, addrMalformedBurst)
+}
+
+// Ban refuses the packets of the address for the duration.
+func (ag *AbuseGuard) Ban(addr *net.UDPAddr, now time.Time, d time.Duration) {
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+	ag.ban(addr, ag.getAddr(addr, now), now, d)
+}
+
+func (ag *AbuseGuard) ban(addr *net.UDPAddr, guard *addrGuard, now time.Time, d time.Duration) {
+	logger(LogNetwork).Warn("address banned", "ip", addr.IP.String(), "duration", d)
+	atomic.AddUint64(&ag.stats.Banned, 1)
+	guard.bannedUntil = now.Add(d)
+}
+
+// Dropped records a packet dropped since its room is busy.
+func (ag *AbuseGuard) Dropped() {
+	atomic.AddUint64(&ag.stats.Dropped, 1)
+}
+
+func (ag *AbuseGuard) GetStats() AbuseStats {
+	return AbuseStats{
+		RateLimited:   atomic.LoadUint64(&ag.stats.RateLimited),
+		JoinLimited:   atomic.LoadUint64(&ag.stats.JoinLimited),
+		Malformed:     atomic.LoadUint64(&ag.stats.Malformed),
+		Banned:        atomic.LoadUint64(&ag.stats.Banned),
+		BannedPackets: atomic.LoadUint64(&ag.stats.BannedPackets),
+		Dropped:       atomic.LoadUint64(&ag.stats.Dropped),
+	}
+}
+
+// getAddr returns the guard of the address, the caller holds the lock.
+func (ag *AbuseGuard) getAddr(addr *net.UDPAddr, now time.Time) *addrGuard {
+	key := addr.IP.String()
+	guard := ag.addrs[key]
+	if guard == nil {
+		guard = &addrGuard{
+			packets:   NewTokenBucket(addrPacketRate, addrPacketBurst),
+			joins:     NewTokenBucket(addrJoinRate, addrJoinBurst),
+			malformed: NewTokenBucket(addrMalformedRate, addrMalformedBurst),
+			rehandshakes: NewTokenBucket(
+				addrRehandshakeRate, addrRehandshakeBurst,
+			),
+		}
+		ag.addrs[key] = guard
+	}
+	guard.lastSeen = now
+	return guard
+}
+
+// sweep forgets the idle addresses which are not banned.
+func (ag *AbuseGuard) sweep(now time.Time) {
+	if now.Sub(ag.lastSweep) < addrIdleExpiry {
+		return
+	}
+	ag.lastSweep = now
+	for key, guard := range ag.addrs {
+		if now.Sub(guard.lastSeen) > addrIdleExpiry && now.After(guard.bannedUntil) {
+			delete(ag.addrs, key)
+		}
+	}
+}
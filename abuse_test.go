@@ -0,0 +1,48 @@
+package gosnake
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAbuseGuardRateLimit(t *testing.T) {
+	ag := NewAbuseGuard()
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000}
+	other := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 2000}
+	now := time.Now()
+	for i := 0; i < addrPacketBurst; i++ {
+		if !ag.Allow(addr, now) {
+			t.Fatalf("packet %d is limited within the burst", i)
+		}
+	}
+	// the ports of an IP share the limit
+	if ag.Allow(other, now) {
+		t.Errorf("packet over the burst is allowed")
+	}
+	if !ag.Allow(addr, now.Add(time.Second)) {
+		t.Errorf("packet is limited after the bucket refills")
+	}
+	if got := ag.GetStats().RateLimited; got != 1 {
+		t.Errorf("got %d rate limited packets, want 1", got)
+	}
+}
+
+func TestAbuseGuardBan(t *testing.T) {
+	ag := NewAbuseGuard()
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 1000}
+	now := time.Now()
+	for i := 0; i <= addrMalformedBurst; i++ {
+		ag.Malformed(addr, now)
+	}
+	if ag.Allow(addr, now.Add(time.Second)) {
+		t.Errorf("banned address is allowed")
+	}
+	if !ag.Allow(addr, now.Add(addrBanDuration+time.Second)) {
+		t.Errorf("address is still banned after the ban expires")
+	}
+	stats := ag.GetStats()
+	if stats.Banned != 1 || stats.BannedPackets != 1 {
+		t.Errorf("got stats %+v, want 1 ban and 1 banned packet", stats)
+	}
+}
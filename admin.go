@@ -0,0 +1,418 @@
+package gosnake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	maxNoticeLength   = 200
+	adminBodyLimit    = 64 * 1024
+	adminReadTimeout  = 10 * time.Second
+	adminWriteTimeout = 10 * time.Second
+)
+
+var (
+	errRoomNotFound    = errors.New("room not found")
+	errPlayerNotFound  = errors.New("player not found")
+	errInvalidPlayerID = errors.New("invalid player id")
+	errRoomStopped     = errors.New("room is stopped")
+)
+
+// RoomStatus is the state of the room reported by the admin API.
+type RoomStatus struct {
+	ID      int             `json:"id"`
+	Options RoomOptions     `json:"options"`
+	Paused  bool            `json:"paused"`
+	Retired bool            `json:"retired"`
+	Tick    uint64          `json:"tick"`
+	Players []*PlayerStatus `json:"players"`
+}
+
+// getStatus returns the status of the room, it runs in the room goroutine.
+func (room *Room) getStatus() *RoomStatus {
+	status := &RoomStatus{
+		ID:      room.id,
+		Options: *room.options.clone(),
+		Paused:  room.paused,
+		Retired: room.IsRetired(),
+		Tick:    room.tick,
+		Players: make([]*PlayerStatus, 0, len(room.players)),
+	}
+	for _, player := range room.players {
+		status.Players = append(status.Players, player.GetStatus(room.id))
+	}
+	return status
+}
+
+// GetStatus returns the status of the room, ok is false if the room is
+// not running anymore.
+func (room *Room) GetStatus() (status *RoomStatus, ok bool) {
+	ok = room.Call(func() {
+		status = room.getStatus()
+	})
+	return
+}
+
+// Kick removes the player from the room with the reason.
+func (room *Room) Kick(playerID, reason string) error {
+	var err error
+	if !room.Call(func() {
+		player := room.players[playerID]
+		if player == nil {
+			err = errPlayerNotFound
+			return
+		}
+		logger(LogRoom).Info(
+			"player kicked", "room", room.id, "player", playerID, "reason", reason,
+		)
+		room.sendError(player.addr, player.GetDatagramSize(), reason)
+		room.removePlayer(player)
+		room.dirty = true
+	}) {
+		return errRoomStopped
+	}
+	return err
+}
+
+// SetPaused pauses or resumes the snakes of all the players.
+func (room *Room) SetPaused(paused bool) bool {
+	return room.Call(func() {
+		room.paused = paused
+		room.broadcastNotice(IfStr(paused, "room paused", "room resumed"))
+	})
+}
+
+// SetSpeed changes the interval the snakes move by themselves, the game
+// goes on with the new speed.
+func (room *Room) SetSpeed(ms int) error {
+	var err error
+	if !room.Call(func() {
+		options := room.options.clone()
+		options.AutoMoveIntervalMS = ms
+		if err = options.Validate(); err != nil {
+			return
+		}
+		room.optionsMu.Lock()
+		room.options.AutoMoveIntervalMS = ms
+		room.optionsMu.Unlock()
+		room.autoticker.Reset(room.getTickInterval())
+		room.broadcastNotice(fmt.Sprintf("speed set to %d ms", ms))
+	}) {
+		return errRoomStopped
+	}
+	return err
+}
+
+// Broadcast sends the notice to all the players of the room.
+func (room *Room) Broadcast(message string) bool {
+	return room.Call(func() {
+		room.broadcastNotice(message)
+	})
+}
+
+// Close retires the room and removes all of its players with the reason,
+// the room stops right after.
+func (room *Room) Close(reason string) bool {
+	atomic.StoreInt32(&room.retired, 1)
+	return room.Call(func() {
+		for _, player := range room.players {
+			room.sendError(player.addr, player.GetDatagramSize(), reason)
+			room.removePlayer(player)
+		}
+	})
+}
+
+func (room *Room) broadcastNotice(message string) {
+	for _, player := range room.players {
+		room.sendNotice(player, message)
+	}
+}
+
+// startAdmin serves the admin API on the admin address, the listener is
+// closed by the returned server.
+func (s *Server) startAdmin() (*http.Server, error) {
+	s.mu.RLock()
+	addr, token := s.options.AdminAddr, s.options.AdminToken
+	s.mu.RUnlock()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{
+		Handler:      &adminHandler{server: s, token: token},
+		ReadTimeout:  adminReadTimeout,
+		WriteTimeout: adminWriteTimeout,
+	}
+	s.wg.Add(1)
+	go func() {
+		server.Serve(listener)
+		s.wg.Done()
+	}()
+	return server, nil
+}
+
+// adminHandler serves the JSON endpoints below, the token is required as
+// a bearer token if it is set.
+//
+//	GET  /rooms
+//	POST /rooms                                   the options of the room
+//	GET  /rooms/{id}
+//	POST /rooms/{id}/pause
+//	POST /rooms/{id}/resume
+//	POST /rooms/{id}/close
+//	POST /rooms/{id}/broadcast                    {"message": "..."}
+//	POST /rooms/{id}/players/{player}/kick
+//	POST /rooms/{id}/players/{player}/ban         {"duration": "10m"}
+//	GET  /players
+//	POST /broadcast                               {"message": "..."}
+//	GET  /metrics                                 the Prometheus text format
+type adminHandler struct {
+	server *Server
+	token  string
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" && !secretEqual("Bearer "+h.token, r.Header.Get("Authorization")) {
+		writeAdminError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, adminBodyLimit)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := parts[0]
+	if route == "rooms" && len(parts) > 1 {
+		h.serveRoom(w, r, parts[1:])
+		return
+	}
+	switch {
+	case route == "rooms" && len(parts) == 1 && r.Method == http.MethodGet:
+		h.listRooms(w)
+	case route == "rooms" && len(parts) == 1 && r.Method == http.MethodPost:
+		h.createRoom(w, r)
+	case route == "players" && len(parts) == 1 && r.Method == http.MethodGet:
+		h.listPlayers(w)
+	case route == "broadcast" && len(parts) == 1 && r.Method == http.MethodPost:
+		h.broadcast(w, r, h.server.getRooms())
+	case route == "metrics" && len(parts) == 1 && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		h.server.WriteMetrics(w)
+	default:
+		writeAdminError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// serveRoom serves the endpoints of a room, the parts start with the id
+// of the room.
+func (h *adminHandler) serveRoom(w http.ResponseWriter, r *http.Request, parts []string) {
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid room id")
+		return
+	}
+	room := h.server.getRoom(id)
+	if room == nil {
+		writeAdminError(w, http.StatusNotFound, errRoomNotFound.Error())
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.getRoom(w, room)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	switch {
+	case len(parts) == 2 && parts[1] == "pause":
+		writeAdminResult(w, room.SetPaused(true))
+	case len(parts) == 2 && parts[1] == "resume":
+		writeAdminResult(w, room.SetPaused(false))
+	case len(parts) == 2 && parts[1] == "close":
+		logger(LogServer).Info("room closed by admin", "room", room.GetID())
+		writeAdminResult(w, room.Close(errRoomRetired.Error()))
+	case len(parts) == 2 && parts[1] == "broadcast":
+		h.broadcast(w, r, []*Room{room})
+	case len(parts) == 4 && parts[1] == "players" && parts[3] == "kick":
+		h.kick(w, room, parts[2], "kicked by the server")
+	case len(parts) == 4 && parts[1] == "players" && parts[3] == "ban":
+		h.ban(w, r, room, parts[2])
+	default:
+		writeAdminError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *adminHandler) listRooms(w http.ResponseWriter) {
+	rooms := h.server.getRooms()
+	statuses := make([]*RoomStatus, 0, len(rooms))
+	for _, room := range rooms {
+		if status, ok := room.GetStatus(); ok {
+			statuses = append(statuses, status)
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, statuses)
+}
+
+func (h *adminHandler) getRoom(w http.ResponseWriter, room *Room) {
+	status, ok := room.GetStatus()
+	if !ok {
+		writeAdminError(w, http.StatusGone, errRoomStopped.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, status)
+}
+
+func (h *adminHandler) listPlayers(w http.ResponseWriter) {
+	players := make([]*PlayerStatus, 0)
+	for _, room := range h.server.getRooms() {
+		if status, ok := room.GetStatus(); ok {
+			players = append(players, status.Players...)
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, players)
+}
+
+// createRoom starts a room with the options of the body on top of the
+// room options of the server.
+func (h *adminHandler) createRoom(w http.ResponseWriter, r *http.Request) {
+	s := h.server
+	s.mu.RLock()
+	options := s.options.RoomOptions.clone()
+	s.mu.RUnlock()
+	if err := decodeAdminBody(r, options); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := options.Validate(); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.IsDraining() {
+		writeAdminError(w, http.StatusServiceUnavailable, shutdownReason)
+		return
+	}
+	s.mu.Lock()
+	if s.countActiveRooms() >= s.options.MaxRooms {
+		s.mu.Unlock()
+		writeAdminError(w, http.StatusConflict, "too many rooms")
+		return
+	}
+	room := s.startRoom(options, false)
+	s.mu.Unlock()
+	logger(LogServer).Info("room created by admin", "room", room.GetID())
+	h.getRoom(w, room)
+}
+
+func (h *adminHandler) broadcast(w http.ResponseWriter, r *http.Request, rooms []*Room) {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := decodeAdminBody(r, &body); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Message == "" || len(body.Message) > maxNoticeLength {
+		writeAdminError(
+			w, http.StatusBadRequest,
+			fmt.Sprintf("message length must be in [1, %d]", maxNoticeLength),
+		)
+		return
+	}
+	for _, room := range rooms {
+		room.Broadcast(body.Message)
+	}
+	writeAdminResult(w, true)
+}
+
+func (h *adminHandler) kick(w http.ResponseWriter, room *Room, playerID, reason string) {
+	switch err := room.Kick(playerID, reason); err {
+	case nil:
+		writeAdminResult(w, true)
+	case errPlayerNotFound:
+		writeAdminError(w, http.StatusNotFound, err.Error())
+	default:
+		writeAdminError(w, http.StatusGone, err.Error())
+	}
+}
+
+// ban bans the address of the player for the duration and kicks it, the
+// duration defaults to the ban of the malformed packets.
+func (h *adminHandler) ban(w http.ResponseWriter, r *http.Request, room *Room, playerID string) {
+	var body struct {
+		Duration string `json:"duration"`
+	}
+	if err := decodeAdminBody(r, &body); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	d := addrBanDuration
+	if body.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(body.Duration); err != nil || d <= 0 {
+			writeAdminError(w, http.StatusBadRequest, "invalid duration")
+			return
+		}
+	}
+	switch err := h.server.banPlayer(room, playerID, d); err {
+	case nil:
+		writeAdminResult(w, true)
+	case errInvalidPlayerID:
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+	case errPlayerNotFound:
+		writeAdminError(w, http.StatusNotFound, err.Error())
+	default:
+		writeAdminError(w, http.StatusGone, err.Error())
+	}
+}
+
+// banPlayer bans the address of the player for the duration and kicks it.
+func (s *Server) banPlayer(room *Room, playerID string, d time.Duration) error {
+	addr, err := net.ResolveUDPAddr("udp", playerID)
+	if err != nil {
+		return errInvalidPlayerID
+	}
+	s.guard.Ban(addr, time.Now(), d)
+	return room.Kick(playerID, "banned by the server")
+}
+
+// decodeAdminBody decodes the JSON body into v, the empty body leaves v
+// unchanged.
+func decodeAdminBody(r *http.Request, v interface{}) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return decodeConfig(data, v)
+}
+
+func writeAdminResult(w http.ResponseWriter, ok bool) {
+	if !ok {
+		writeAdminError(w, http.StatusGone, errRoomStopped.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func writeAdminError(w http.ResponseWriter, code int, message string) {
+	writeAdminJSON(w, code, map[string]string{"error": message})
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,68 @@
+package gosnake
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	options := DefaultServerOptions.clone()
+	options.Addr = "127.0.0.1:0"
+	options.RoomSize = 2
+	server := NewServer(options)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx)
+	for len(server.getRooms()) < options.RoomSize {
+		time.Sleep(time.Millisecond)
+	}
+
+	handler := &adminHandler{server: server, token: "secret"}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/rooms", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d without the token, want 401", w.Code)
+	}
+	for _, c := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/rooms", "", http.StatusOK},
+		{http.MethodGet, "/rooms/1", "", http.StatusOK},
+		{http.MethodGet, "/rooms/7", "", http.StatusNotFound},
+		{http.MethodPost, "/rooms/1/pause", "", http.StatusOK},
+		{http.MethodPost, "/rooms/1/players/127.0.0.1:1/kick", "", http.StatusNotFound},
+		{http.MethodPost, "/broadcast", `{"message": ""}`, http.StatusBadRequest},
+		{http.MethodPost, "/rooms", `{"border_width": 2}`, http.StatusBadRequest},
+		{http.MethodPost, "/rooms", `{"border_width": 16}`, http.StatusOK},
+		{http.MethodPost, "/rooms/2/close", "", http.StatusOK},
+	} {
+		if w := do(c.method, c.path, c.body); w.Code != c.code {
+			t.Errorf("%s %s: got %d %s, want %d", c.method, c.path, w.Code, w.Body, c.code)
+		}
+	}
+
+	// the closed room is gone until it stops, then it is forgotten
+	deadline := time.Now().Add(time.Second)
+	w = do(http.MethodGet, "/rooms/2", "")
+	for w.Code == http.StatusGone && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		w = do(http.MethodGet, "/rooms/2", "")
+	}
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /rooms/2 after close: got %d %s, want 404", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,129 @@
+package gosnake
+
+import (
+	"fmt"
+	"gosnake/keys"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxChatLength = 80
+	chatRate      = 0.5
+	chatBurst     = 5
+	chatHistory   = 6
+)
+
+// chatEmotes are sent by the number keys 1 to 9.
+var chatEmotes = []string{
+	"gg", "nice one", "oops", "too slow!", "come at me",
+	"watch your tail", "lol", "rip", "bye",
+}
+
+// ChatData is a chat message of a player sent to all the players of the
+// room.
+type ChatData struct {
+	PlayerID string
+	Text     string
+}
+
+// AllowChat reports whether the player may send a chat message, the
+// messages over the rate are refused.
+func (player *Player) AllowChat(now time.Time) bool {
+	return player.chatLimit.Allow(now, 1)
+}
+
+// sanitizeChat drops the control characters so that a message can not
+// mess up the terminals of the players.
+func sanitizeChat(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.TrimSpace(text)
+}
+
+// playerChat sends the message of the player to all the players of the
+// room.
+func (room *Room) playerChat(player *Player, text string) {
+	text = sanitizeChat(text)
+	if text == "" {
+		return
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		room.sendNotice(player, "message is too long")
+		return
+	}
+	if !player.AllowChat(time.Now()) {
+		room.sendNotice(player, "you are chatting too fast")
+		return
+	}
+	logger(LogGame).Info("chat", "room", room.id, "player", player.GetID(), "text", text)
+	data := (&ServerData{
+		Chat: &ChatData{PlayerID: player.GetID(), Text: text},
+	}).Encode()
+	for _, p := range room.players {
+		room.writer.Send(data, p.addr, p.GetDatagramSize())
+	}
+}
+
+// handleChatKey edits the chat input, the message is sent on enter and
+// dropped on escape.
+func (client *Client) handleChatKey(keycode keys.Code) {
+	switch {
+	case keycode == keys.CodeEnter || keycode == '\n':
+		if text := sanitizeChat(string(client.chatInput)); text != "" {
+			client.sendChat(text)
+		}
+		client.chatting = false
+		client.chatInput = client.chatInput[:0]
+	case keycode == keys.CodeEscape:
+		client.chatting = false
+		client.chatInput = client.chatInput[:0]
+	case keycode == keys.CodeBackspace || keycode == '\b':
+		_, size := utf8.DecodeLastRune(client.chatInput)
+		client.chatInput = client.chatInput[:len(client.chatInput)-size]
+	case keycode >= ' ' && len(client.chatInput) < maxChatLength:
+		client.chatInput = append(client.chatInput, byte(keycode))
+	}
+	client.updateFrame()
+}
+
+func (client *Client) sendChat(text string) {
+	client.sendData(&ClientData{
+		RoomID: client.roomID,
+		CMD:    CMDChat,
+		Text:   text,
+	})
+}
+
+func (client *Client) handleChat(chat *ChatData) {
+	logger(LogClient).Info("chat", "player", chat.PlayerID, "text", chat.Text)
+	client.chat = append(client.chat, chat)
+	if len(client.chat) > chatHistory {
+		client.chat = client.chat[len(client.chat)-chatHistory:]
+	}
+	client.updateFrame()
+}
+
+// getChatTexts returns the chat panel shown next to the board.
+func (client *Client) getChatTexts(playerID string) (texts Lines) {
+	texts = append(texts, "\033[1m chat\033[0m")
+	for _, chat := range client.chat {
+		texts = append(texts, fmt.Sprintf(
+			" %s: %s", client.getPlayerName(playerID, chat.PlayerID),
+			sanitizeChat(chat.Text),
+		))
+	}
+	for len(texts) <= chatHistory {
+		texts = append(texts, "")
+	}
+	if client.chatting {
+		return append(texts, fmt.Sprintf(" > %s\033[7m \033[0m", client.chatInput))
+	}
+	return append(texts, " t: chat   1-9: emotes")
+}
@@ -0,0 +1,74 @@
+package gosnake
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeChat(t *testing.T) {
+	for in, want := range map[string]string{
+		"gg":                  "gg",
+		"  hi there \t":       "hi there",
+		"\033[2Jboom":         "[2Jboom",
+		"line\r\nbreak":       "linebreak",
+		"\x00\x07":            "",
+		"caf\xc3\xa9 \xff ok": "café  ok",
+	} {
+		if got := sanitizeChat(in); got != want {
+			t.Errorf("sanitizeChat(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPlayerChat(t *testing.T) {
+	var sent []*ServerData
+	send := func(data []byte, addr *net.UDPAddr, datagramSize int) {
+		sd, err := DecodeServerData(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, sd)
+	}
+	room := NewRoom(0, DefaultServerOptions.RoomOptions, send)
+	room.Init()
+	addrs := []*net.UDPAddr{
+		{IP: net.IPv4(127, 0, 0, 1), Port: 1},
+		{IP: net.IPv4(127, 0, 0, 1), Port: 2},
+	}
+	for _, addr := range addrs {
+		room.handleData(&RoomData{Sender: addr, ClientData: &ClientData{CMD: CMDJoin}})
+	}
+	chat := func(text string) {
+		room.handleData(&RoomData{
+			Sender:     addrs[0],
+			ClientData: &ClientData{CMD: CMDChat, Text: text},
+		})
+	}
+	room.writer.flush()
+	sent = nil
+
+	chat("hello")
+	chat(strings.Repeat("x", maxChatLength+1))
+	for i := 0; i < chatBurst; i++ {
+		chat("spam")
+	}
+	room.writer.flush()
+
+	var chats, notices []string
+	for _, sd := range sent {
+		if sd.Chat != nil {
+			chats = append(chats, sd.Chat.PlayerID+" "+sd.Chat.Text)
+		}
+		if sd.Notice != nil {
+			notices = append(notices, sd.Notice.Message)
+		}
+	}
+	// each message is sent to both players, the last spam is over the burst
+	if len(chats) != 2*chatBurst || chats[0] != "127.0.0.1:1 hello" {
+		t.Errorf("got chats %q", chats)
+	}
+	if strings.Join(notices, ",") != "message is too long,you are chatting too fast" {
+		t.Errorf("got notices %q", notices)
+	}
+}
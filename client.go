@@ -3,6 +3,8 @@ package gosnake
 import (
 	"bytes"
 	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
 	"encoding/gob"
 	"fmt"
 	"gosnake/keys"
@@ -13,16 +15,31 @@ import (
 	"time"
 )
 
+const (
+	connWatchInterval = 200 * time.Millisecond
+	noticeDuration    = 10 * time.Second
+	// rehandshakeInterval is the least time between the handshakes sent
+	// since the server lost the session, it is below the join rate.
+	rehandshakeInterval = 2 * time.Second
+)
+
 var DefaultClientOptions = &ClientOptions{
-	PingIntervalMs:    1000,
-	ServerAddr:        "127.0.0.1:9001",
-	RoomID:            0,
-	SnakeSymbol:       "\033[41;1;37m[]\033[0m",
-	PlayerSnakeSymbol: "\033[44;1;37m[]\033[0m",
-	BorderSymbol:      "\033[46;1;37m[]\033[0m",
-	FoodSymbol:        "\033[42;1;37m[]\033[0m",
-	GroundSymbol:      "  ",
-	FPS:               30,
+	PingIntervalMs:   1000,
+	ServerAddr:       "127.0.0.1:9001",
+	RoomID:           0,
+	SnakeColors:      []string{"41", "43", "45", "101", "103", "105"},
+	PlayerSnakeColor: "44",
+	OverSnakeColor:   "100",
+	BorderSymbol:     "\033[46;1;37m[]\033[0m",
+	FoodSymbol:       "\033[42;1;37m[]\033[0m",
+	GoldenFoodSymbol: "\033[43;1;37m[]\033[0m",
+	GroundSymbol:     "  ",
+	FPS:              30,
+
+	LostTimeoutMs:      3000,
+	ReconnectBackoffMs: 500,
+	ReconnectLimitMs:   30000,
+	MaxDatagramSize:    splitChildPackageSize,
 }
 
 func RunClient(ctx context.Context) error {
@@ -30,20 +47,34 @@ func RunClient(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	client.Run(ctx)
-	return nil
+	return client.Run(ctx)
 }
 
 type ClientOptions struct {
-	PingIntervalMs    int
-	ServerAddr        string
-	RoomID            int
-	SnakeSymbol       string
-	PlayerSnakeSymbol string
-	FoodSymbol        string
-	BorderSymbol      string
-	GroundSymbol      string
-	FPS               int
+	PingIntervalMs   int
+	ServerAddr       string
+	RoomID           int
+	SnakeColors      []string
+	PlayerSnakeColor string
+	OverSnakeColor   string
+	FoodSymbol       string
+	GoldenFoodSymbol string
+	BorderSymbol     string
+	GroundSymbol     string
+	FPS              int
+
+	LostTimeoutMs      int
+	ReconnectBackoffMs int
+	ReconnectLimitMs   int
+	MaxDatagramSize    int
+	// Secure needs the public key of the server, which the handshake
+	// is signed with.
+	Secure    bool
+	ServerKey string
+
+	Password   string
+	InviteCode string
+	CreateRoom bool
 }
 
 type Client struct {
@@ -59,11 +90,54 @@ type Client struct {
 	once         *sync.Once
 	cancel       context.CancelFunc
 	frame        string
+	scene        *SceneData
+	predictor    *Predictor
+	latency      LatencyStats
+	loss         LossEstimator
+	sceneRecv    time.Time
+	conn         *Connection
+	watchTicker  *time.Ticker
+	handshakeKey *ecdh.PrivateKey
+	handshakeAt  time.Time
+	serverKey    ed25519.PublicKey
+	session      *SecureSession
+	roomID       int
+	roomPrivate  bool
+	inviteCode   string
+	roomCreated  bool
+	notice       string
+	noticeUntil  time.Time
+	chat         []*ChatData
+	chatting     bool
+	chatInput    []byte
+	feed         []*GameEvent
+	eventSeq     uint32
+	death        *DeathData
 }
 
 func NewClient(options *ClientOptions) (client *Client, err error) {
-	client = &Client{options: options, once: &sync.Once{}}
+	client = &Client{
+		options:    options,
+		once:       &sync.Once{},
+		predictor:  NewPredictor(),
+		roomID:     options.RoomID,
+		inviteCode: options.InviteCode,
+	}
+	if options.Secure {
+		if client.serverKey, err = ParseServerKey(options.ServerKey); err != nil {
+			return nil, err
+		}
+	}
+	client.conn = NewConnection(
+		time.Duration(options.LostTimeoutMs)*time.Millisecond,
+		time.Duration(options.ReconnectBackoffMs)*time.Millisecond,
+		time.Duration(options.ReconnectLimitMs)*time.Millisecond,
+	)
 	client.frame = "\rWaiting for server response...\033[K"
+	client.watchTicker = time.NewTicker(connWatchInterval)
+	client.clearFuncs = append(
+		client.clearFuncs, client.watchTicker.Stop,
+	)
 	client.pingTicker = time.NewTicker(
 		time.Duration(options.PingIntervalMs) * time.Millisecond,
 	)
@@ -77,7 +151,7 @@ func NewClient(options *ClientOptions) (client *Client, err error) {
 		client.clearFuncs, client.renderTicker.Stop,
 	)
 	client.network = NewNetWork(
-		splitChildPackageSize, splitChildPackageNum,
+		uint32(options.MaxDatagramSize), splitChildPackageNum,
 	)
 	err = client.network.Start("", client.options.ServerAddr)
 	if err != nil {
@@ -97,14 +171,15 @@ func NewClient(options *ClientOptions) (client *Client, err error) {
 		"************************ GOSNAKE@v0.0.1 ************************",
 		"****************************************************************",
 		" * Up: w,i   Left: a,j  Down: s,k  Right: d,j",
-		" * Pause: p  Replay: r  Quit: q",
+		" * Pause: p  Replay: r  Quit: q  Boost: space",
+		" * Chat: t   Emotes: 1-9",
 		"----------------------------------------------------------------",
-		" * rank   players                   score   state               ",
+		" * rank   players                   score   kills   state    ping       ",
 	}
 	return
 }
 
-func (client *Client) Run(ctx context.Context) {
+func (client *Client) Run(ctx context.Context) error {
 	defer client.clear()
 
 	fmt.Print("\033[?25l")
@@ -114,28 +189,175 @@ func (client *Client) Run(ctx context.Context) {
 	cmd.Run()
 
 	ctx, client.cancel = context.WithCancel(ctx)
+	client.watch()
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
+		case <-client.watchTicker.C:
+			if err := client.watch(); err != nil {
+				return err
+			}
 		case keycode := <-client.keyEvents:
 			client.handleKeycode(keycode)
 		case <-client.pingTicker.C:
-			client.sendCMD(CMDPing)
+			client.ping()
 		case data := <-client.network.Recv:
-			client.update(data)
+			if err := client.update(data); err != nil {
+				return err
+			}
 		case <-client.renderTicker.C:
+			if client.predictor.Blend() {
+				client.updateFrame()
+			}
 			client.render()
 		}
 
 	}
 }
 
+// watch sends the join when the client is joining or reconnecting, and
+// updates the frame when the connection state changes.
+func (client *Client) watch() error {
+	joined := client.conn.IsJoined()
+	join, err := client.conn.Check(time.Now())
+	if err != nil {
+		logger(LogClient).Error("server lost", "err", err)
+		return err
+	}
+	if join {
+		logger(LogClient).Info(
+			"joining", "server", client.options.ServerAddr, "room", client.roomID,
+			"secure", client.options.Secure,
+		)
+		client.join()
+	}
+	if join || joined != client.conn.IsJoined() {
+		client.updateFrame()
+	}
+	return nil
+}
+
+// join sends the join, in secure mode a handshake with a new key is sent
+// instead, and the join follows the reply of the handshake. The handshake
+// is sealed with the current session if any, the server only replaces a
+// live session with a handshake proven by it.
+func (client *Client) join() {
+	if !client.options.Secure {
+		client.sendJoin()
+		return
+	}
+	client.sendHandshake(client.session != nil)
+}
+
+// sendHandshake sends the handshake with a new key, it is sealed with the
+// current session if asked.
+func (client *Client) sendHandshake(sealed bool) {
+	key, err := NewKeyPair()
+	if err != nil {
+		logger(LogClient).Error("failed to create key", "err", err)
+		return
+	}
+	client.handshakeKey = key
+	client.handshakeAt = time.Now()
+	data := client.encodeClientData(&ClientData{
+		RoomID:    client.roomID,
+		CMD:       CMDHandshake,
+		PublicKey: key.PublicKey().Bytes(),
+	})
+	if sealed {
+		data = client.session.Seal(data)
+	}
+	client.network.Send <- data
+}
+
+// handleRehandshake handshakes again in plain since the server lost the
+// session. The session is kept until the handshake is replied, so that a
+// spoofed error can not break it. The error is replied to every sealed
+// packet, so the handshake is resent by the rehandshake interval only.
+func (client *Client) handleRehandshake() {
+	if !client.options.Secure || client.session == nil {
+		return
+	}
+	if client.handshakeKey != nil && time.Since(client.handshakeAt) < rehandshakeInterval {
+		return
+	}
+	logger(LogClient).Info("session expired, handshaking again")
+	client.sendHandshake(false)
+}
+
+// sendJoin sends the join with the secret of the room, a private room is
+// created at first if the client is asked to.
+func (client *Client) sendJoin() {
+	if client.options.CreateRoom && !client.roomCreated {
+		client.sendData(&ClientData{
+			CMD:      CMDCreateRoom,
+			Password: client.options.Password,
+		})
+		return
+	}
+	client.sendData(&ClientData{
+		RoomID:          client.roomID,
+		CMD:             CMDJoin,
+		MaxDatagramSize: client.options.MaxDatagramSize,
+		Password:        client.options.Password,
+		InviteCode:      client.inviteCode,
+	})
+}
+
+func (client *Client) handleRoomCreated(created *RoomCreatedData) {
+	if client.roomCreated {
+		return
+	}
+	logger(LogClient).Info("room created", "room", created.RoomID)
+	client.roomCreated = true
+	client.roomID = created.RoomID
+	client.inviteCode = created.InviteCode
+	client.sendJoin()
+}
+
+func (client *Client) handleHandshake(handshake *HandshakeData) {
+	if client.handshakeKey == nil {
+		return
+	}
+	clientPub := client.handshakeKey.PublicKey().Bytes()
+	if !verifyHandshake(client.serverKey, clientPub, handshake.PublicKey, handshake.Signature) {
+		logger(LogClient).Warn("handshake is not signed by the server key")
+		return
+	}
+	session, err := NewSecureSession(client.handshakeKey, handshake.PublicKey, false)
+	if err != nil {
+		logger(LogClient).Error("handshake failed", "err", err)
+		return
+	}
+	logger(LogClient).Debug("handshake done")
+	client.session = session
+	client.handshakeKey = nil
+	client.sendJoin()
+}
+
 func (client *Client) handleKeycode(keycode keys.Code) {
+	if client.chatting {
+		client.handleChatKey(keycode)
+		return
+	}
+	switch {
+	case keycode == keys.CodeChat:
+		client.chatting = true
+		client.updateFrame()
+		return
+	case keycode >= keys.CodeEmote1 && keycode <= keys.CodeEmote9:
+		client.sendChat(chatEmotes[keycode-keys.CodeEmote1])
+		return
+	}
 	cmd := GetKeyCodeCMD(keycode)
 	if cmd == "" {
 		return
 	}
+	if dir, ok := GetCMDDir(cmd); ok {
+		client.handleMovCMD(cmd, dir)
+		return
+	}
 	client.sendCMD(cmd)
 	if cmd == CMDQuit {
 		time.Sleep(500 * time.Millisecond)
@@ -143,25 +365,225 @@ func (client *Client) handleKeycode(keycode keys.Code) {
 	}
 }
 
-func (client *Client) update(data []byte) {
-	sceneData := DecodeSceneData(data)
+// handleMovCMD sends the turn to the server and shows the predicted
+// result at once, a turn queued behind another is shown after the server
+// applies the first one.
+func (client *Client) handleMovCMD(cmd CMD, dir Direction) {
+	seq, ok := client.predictor.Input(dir)
+	if !ok {
+		return
+	}
+	client.sendData(&ClientData{
+		RoomID: client.roomID,
+		CMD:    cmd,
+		Seq:    seq,
+	})
+	if client.scene != nil {
+		client.predictor.Reconcile(client.scene)
+		client.updateFrame()
+	}
+}
+
+// ping sends the client time for the round trip time measured by the
+// client, and echoes the server time of the last snapshot for the one
+// measured by the server.
+func (client *Client) ping() {
+	now := time.Now()
+	cliData := &ClientData{
+		RoomID:     client.roomID,
+		CMD:        CMDPing,
+		ClientTime: now.UnixNano(),
+	}
+	if client.scene != nil {
+		cliData.EchoServerTime = client.scene.ServerTime
+		cliData.EchoDelay = int64(now.Sub(client.sceneRecv))
+	}
+	client.sendData(cliData)
+}
+
+func (client *Client) update(data []byte) error {
+	if IsRehandshake(data) {
+		client.handleRehandshake()
+		return nil
+	}
+	serverData, err := client.decodeServerData(data)
+	if err != nil {
+		logger(LogNetwork).Debug("failed to decode server data", "err", err)
+		return nil
+	}
+	if serverData.Error != nil {
+		logger(LogClient).Error("server error", "message", serverData.Error.Message)
+		return fmt.Errorf("server: %s", serverData.Error.Message)
+	}
+	if serverData.Handshake != nil {
+		client.handleHandshake(serverData.Handshake)
+		return nil
+	}
+	if serverData.RoomCreated != nil {
+		client.handleRoomCreated(serverData.RoomCreated)
+		return nil
+	}
+	if serverData.Notice != nil {
+		logger(LogClient).Info("notice", "message", serverData.Notice.Message)
+		client.notice = serverData.Notice.Message
+		client.noticeUntil = time.Now().Add(noticeDuration)
+	}
+	if serverData.Chat != nil {
+		client.handleChat(serverData.Chat)
+	}
+	if serverData.Death != nil {
+		client.handleDeath(serverData.Death)
+	}
+	if serverData.Join != nil {
+		logger(LogClient).Info(
+			"joined", "room", serverData.Join.RoomID,
+			"datagram_size", serverData.Join.DatagramSize,
+		)
+		client.roomID = serverData.Join.RoomID
+		client.roomPrivate = serverData.Join.Private
+		client.eventSeq = serverData.Join.EventSeq
+		client.loss.Reset()
+	}
+	joined := client.conn.IsJoined()
+	client.conn.Received(time.Now())
+	if serverData.Pong != nil {
+		rtt := time.Now().UnixNano() - serverData.Pong.ClientTime
+		client.latency.Update(time.Duration(rtt))
+	}
+	if serverData.Scene != nil {
+		client.updateScene(serverData.Scene)
+	} else if !joined {
+		client.updateFrame()
+	}
+	return nil
+}
+
+// decodeServerData opens the sealed data with the session, in secure mode
+// only the handshake is accepted in plain.
+func (client *Client) decodeServerData(data []byte) (*ServerData, error) {
+	if IsSealed(data) {
+		if client.session == nil {
+			return nil, errNotSealed
+		}
+		opened, err := client.session.Open(data)
+		if err != nil {
+			return nil, err
+		}
+		return DecodeServerData(opened)
+	}
+	serverData, err := DecodeServerData(data)
+	if err != nil {
+		return nil, err
+	}
+	if client.options.Secure && serverData.Handshake == nil {
+		return nil, errNotSealed
+	}
+	return serverData, nil
+}
+
+func (client *Client) updateScene(sceneData *SceneData) {
+	client.sceneRecv = time.Now()
+	client.loss.Receive(sceneData.Seq)
+	client.handleEvents(sceneData.Events)
+	client.checkReplayed(sceneData)
+	client.predictor.Reconcile(sceneData)
+	client.scene = sceneData
+	client.updateFrame()
+}
+
+func (client *Client) updateFrame() {
+	sceneData := client.scene
+	if sceneData == nil {
+		client.frame = "\r" + client.conn.GetStatus() + "\033[K"
+		return
+	}
 	client.once.Do(func() {
 		client.ground = NewGround(sceneData.BorderWidth, sceneData.BorderHeight, client.options.GroundSymbol)
 		client.border = NewRecBorder(sceneData.BorderWidth, sceneData.BorderHeight, client.options.BorderSymbol)
 	})
 	sceneData.Food.SetSymbol(client.options.FoodSymbol)
-	sceneData.Snakes.SetSymbol(client.options.SnakeSymbol)
-	sceneData.PlayerSnake.SetSymbol(client.options.PlayerSnakeSymbol)
-	layers := []Layer{client.border, sceneData.Food, sceneData.Snakes, sceneData.PlayerSnake}
+	goldenFood := NewCompressLayer(sceneData.Food.W, sceneData.Food.H)
+	goldenFood.SetSymbol(client.options.GoldenFoodSymbol)
+	for _, pos := range sceneData.GoldenFood {
+		goldenFood.AddPositions(map[Position]struct{}{pos: {}})
+	}
+	snakes := NewSnakeLayer()
+	for _, sd := range sceneData.Snakes {
+		if sd.ID == sceneData.PlayerID {
+			if predicted := client.predictor.GetSnakeData(sd.ID); predicted != nil {
+				predicted.Protected, predicted.Over = sd.Protected, sd.Over
+				sd = predicted
+			}
+		}
+		snakes.AddSnake(sd, client.getSnakeColor(sceneData.PlayerID, sd))
+	}
+	layers := []Layer{client.border, sceneData.Food, goldenFood, snakes}
 	texts := client.getPlayerStatsTexts(sceneData.PlayerID, sceneData.PlayerStats)
 	texts = append(client.texts[:], texts...)
-	client.frame = client.ground.Render(layers...).PreAppend(
+	texts = append(
+		texts, client.getRoomText(), client.getNetworkText(),
+		client.conn.GetStatus(), client.getNoticeText(),
+	)
+	panel := append(client.getChatTexts(sceneData.PlayerID), "")
+	panel = append(panel, client.getFeedTexts(sceneData.PlayerID)...)
+	board := client.ground.Render(layers...)
+	boardWidth := sceneData.BorderWidth * 2
+	if client.death != nil {
+		overlay, left := client.getPostMortemTexts(
+			client.death, boardWidth, sceneData.BorderHeight,
+		)
+		board = board.HozJoin(overlay, left)
+	}
+	board = board.HozJoin(panel, boardWidth+2)
+	client.frame = board.PreAppend(
 		texts[:1],
 	).Append(
 		texts[1:],
 	).Merge()
 }
 
+func (client *Client) getRoomText() string {
+	if !client.roomPrivate {
+		return fmt.Sprintf(" * room      %d", client.roomID)
+	}
+	if client.inviteCode == "" {
+		return fmt.Sprintf(" * room      %d (private)", client.roomID)
+	}
+	return fmt.Sprintf(
+		" * room      %d (private, invite code: %s)",
+		client.roomID, client.inviteCode,
+	)
+}
+
+// getNoticeText returns the last notice of the server until it expires.
+func (client *Client) getNoticeText() string {
+	if client.notice == "" || time.Now().After(client.noticeUntil) {
+		return ""
+	}
+	return " * notice    " + client.notice
+}
+
+func (client *Client) getNetworkText() string {
+	stats := client.network.GetRecvStats()
+	return fmt.Sprintf(
+		" * network   rtt: %dms   jitter: %dms   loss: %.1f%%   incomplete: %d",
+		client.latency.GetRTT()/time.Millisecond,
+		client.latency.GetJitter()/time.Millisecond,
+		client.loss.GetLoss()*100,
+		stats.Incomplete,
+	)
+}
+
+func (client *Client) getSnakeColor(playerID string, sd *SnakeData) string {
+	if sd.Over {
+		return client.options.OverSnakeColor
+	}
+	if sd.ID == playerID {
+		return client.options.PlayerSnakeColor
+	}
+	return GetSnakeColor(sd.ID, client.options.SnakeColors)
+}
+
 func (client *Client) getPlayerStatsTexts(playerID string, stats PlayerStats) (texts Lines) {
 	sort.Sort(stats)
 	for i, stat := range stats {
@@ -169,18 +591,24 @@ func (client *Client) getPlayerStatsTexts(playerID string, stats PlayerStats) (t
 		if playerID == stat.ID {
 			color = "1;44;37"
 		}
-		state := client.getStateStr(stat.Pause, stat.Over)
+		swatch := IfStr(
+			playerID == stat.ID,
+			client.options.PlayerSnakeColor,
+			GetSnakeColor(stat.ID, client.options.SnakeColors),
+		)
+		state := client.getStateStr(stat.Pause, stat.Over, stat.Boost)
 		line := fmt.Sprintf(
-			"  \033[%sm %d      %-21s     %03d     %-5s  \033[0m",
-			color, i+1, stat.ID, stat.Score, state,
+			"  \033[%sm %d   \033[%sm  \033[0;%sm %-21s     %03d     %03d     %-5s  %4dms  \033[0m",
+			color, i+1, swatch, color, stat.ID, stat.Score, stat.Kills, state, stat.LatencyMS,
 		)
 		texts = append(texts, line)
 	}
 	return
 }
 
-func (client *Client) getStateStr(pause, over bool) (state string) {
-	state = IfStr(pause, "Pause", "Run")
+func (client *Client) getStateStr(pause, over, boost bool) (state string) {
+	state = IfStr(boost, "Boost", "Run")
+	state = IfStr(pause, "Pause", state)
 	state = IfStr(over, "Over", state)
 	return
 }
@@ -190,15 +618,21 @@ func (client *Client) render() {
 }
 
 func (client *Client) sendCMD(cmd CMD) {
-	data := client.encodeClientData(cmd)
-	client.network.Send <- data
+	client.sendData(&ClientData{
+		RoomID: client.roomID,
+		CMD:    cmd,
+	})
 }
 
-func (client *Client) encodeClientData(cmd CMD) []byte {
-	cliData := &ClientData{
-		RoomID: client.options.RoomID,
-		CMD:    cmd,
+func (client *Client) sendData(cliData *ClientData) {
+	data := client.encodeClientData(cliData)
+	if client.session != nil {
+		data = client.session.Seal(data)
 	}
+	client.network.Send <- data
+}
+
+func (client *Client) encodeClientData(cliData *ClientData) []byte {
 	var buf bytes.Buffer
 	encoder := gob.NewEncoder(&buf)
 	err := encoder.Encode(cliData)
@@ -5,21 +5,29 @@ import "gosnake/keys"
 type CMD string
 
 const (
-	CMDPing     CMD = "PING"
-	CMDPong     CMD = "PONG"
-	CMDPause    CMD = "PAUSE"
-	CMDReplay   CMD = "REPLAY"
-	CMDQuit     CMD = "QUIT"
-	CMDMovLeft  CMD = "MOVE_LEFT"
-	CMDMovRight CMD = "MOVE_RIGHT"
-	CMDMovUp    CMD = "MOVE_UP"
-	CMDMovDown  CMD = "MOVE_DOWN"
+	CMDHandshake  CMD = "HANDSHAKE"
+	CMDJoin       CMD = "JOIN"
+	CMDListRooms  CMD = "LIST_ROOMS"
+	CMDCreateRoom CMD = "CREATE_ROOM"
+	CMDPing       CMD = "PING"
+	CMDPong       CMD = "PONG"
+	CMDPause      CMD = "PAUSE"
+	CMDReplay     CMD = "REPLAY"
+	CMDQuit       CMD = "QUIT"
+	CMDBoost      CMD = "BOOST"
+	CMDMovLeft    CMD = "MOVE_LEFT"
+	CMDMovRight   CMD = "MOVE_RIGHT"
+	CMDMovUp      CMD = "MOVE_UP"
+	CMDMovDown    CMD = "MOVE_DOWN"
+	CMDChat       CMD = "CHAT"
+	CMDEventAck   CMD = "EVENT_ACK"
 )
 
 var keyCodeToCMD = map[keys.Code]CMD{
 	keys.CodePause:  CMDPause,
 	keys.CodeReplay: CMDReplay,
 	keys.CodeQuit:   CMDQuit,
+	keys.CodeBoost:  CMDBoost,
 	keys.CodeUp:     CMDMovUp,
 	keys.CodeDown:   CMDMovDown,
 	keys.CodeLeft:   CMDMovLeft,
@@ -6,33 +6,149 @@ import (
 	"fmt"
 	"gosnake"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 )
 
 var (
-	server bool
+	server     bool
+	listRooms  bool
+	console    bool
+	configPath string
 )
 
 func init() {
 	flag.BoolVar(&server, "srv", false, "start as server")
-	flag.StringVar(&(gosnake.DefaultServerOptions.Addr), "listen-addr", "0.0.0.0:9001", "server listen address")
+	flag.BoolVar(&listRooms, "list-rooms", false, "list the rooms of the server")
+	flag.BoolVar(&console, "console", false, "read the operator commands from stdin, type help for the commands")
+	flag.StringVar(&configPath, "config", "", "the JSON configuration file of the server")
+	gosnake.DefaultServerOptions.Addr = "0.0.0.0:9001"
+	bindServerFlags(flag.CommandLine, gosnake.DefaultServerOptions)
 	flag.StringVar(&(gosnake.DefaultClientOptions.ServerAddr), "server-addr", "120.79.9.154:9001", "server address")
+	flag.BoolVar(&(gosnake.DefaultClientOptions.Secure), "secure", false, "encrypt and authenticate the game traffic")
+	flag.StringVar(&(gosnake.DefaultClientOptions.ServerKey), "server-key", "", "the public key of the server required by secure")
+	flag.IntVar(&(gosnake.DefaultClientOptions.MaxDatagramSize), "max-datagram-size", 1350, "the largest datagram the client receives, lower it if the snapshots are lost on the path")
+	flag.IntVar(&(gosnake.DefaultClientOptions.LostTimeoutMs), "lost-timeout-ms", 3000, "how long the client waits without a snapshot before it reconnects")
+	flag.IntVar(&(gosnake.DefaultClientOptions.ReconnectBackoffMs), "reconnect-backoff-ms", 500, "the first delay between the reconnects, doubled after each one up to 8 seconds")
+	flag.IntVar(&(gosnake.DefaultClientOptions.ReconnectLimitMs), "reconnect-limit-ms", 30000, "how long the client waits for the server to respond before it gives up")
+	flag.IntVar(&(gosnake.DefaultClientOptions.RoomID), "room", 0, "the room to join")
+	flag.StringVar(&(gosnake.DefaultClientOptions.Password), "password", "", "the password of the private room")
+	flag.StringVar(&(gosnake.DefaultClientOptions.InviteCode), "invite", "", "the invite code of the private room")
+	flag.BoolVar(&(gosnake.DefaultClientOptions.CreateRoom), "create-room", false, "create a private room and join it")
+	flag.StringVar(&(gosnake.DefaultLogOptions.Level), "log-level", "info", "the log level, one of debug, info, warn and error")
+	flag.StringVar(&(gosnake.DefaultLogOptions.Levels), "log-levels", "", "the log levels of the subsystems network, room, game, server and client, like network=debug,room=warn")
+	flag.StringVar(&(gosnake.DefaultLogOptions.Format), "log-format", "text", "the log format, text or json")
+	flag.StringVar(&(gosnake.DefaultLogOptions.File), "log-file", "", "the file the logs are appended to, the client logs to gosnake.log in the temp dir by default")
+}
+
+// bindServerFlags binds the flags of the server to the options, the flags
+// default to the current values of the options.
+func bindServerFlags(fs *flag.FlagSet, options *gosnake.ServerOptions) {
+	fs.StringVar(&options.Addr, "listen-addr", options.Addr, "server listen address")
+	fs.StringVar(&options.AdminAddr, "admin-addr", options.AdminAddr, "the address of the admin API, disabled if empty")
+	fs.StringVar(&options.AdminToken, "admin-token", options.AdminToken, "the bearer token required by the admin API")
+	fs.IntVar(&options.DrainSeconds, "drain-seconds", options.DrainSeconds, "how long the players may finish their games on shutdown")
+	fs.StringVar(&options.StatsFile, "stats-file", options.StatsFile, "the file the stats are appended to on shutdown")
+	fs.StringVar(&options.KeyFile, "key-file", options.KeyFile, "the file of the key the handshakes are signed with, created if missing")
 }
 
 func main() {
 	flag.Parse()
 
+	// the logs of the client would corrupt the frame on the terminal
+	if !server && !listRooms && gosnake.DefaultLogOptions.File == "" {
+		gosnake.DefaultLogOptions.File = filepath.Join(os.TempDir(), "gosnake.log")
+	}
+	closer, err := gosnake.SetupLogging(gosnake.DefaultLogOptions)
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer closer.Close()
+
 	ctx := context.Background()
-	var err error
 	if server {
-		err = gosnake.RunServer(ctx)
+		err = runServer(ctx)
+	} else if listRooms {
+		err = gosnake.PrintRooms(ctx, gosnake.DefaultClientOptions.ServerAddr)
 	} else {
 		err = gosnake.RunClient(ctx)
 	}
 
 	if err != nil {
+		closer.Close()
 		fmt.Fprint(os.Stderr, err)
 		os.Exit(1)
 	}
+}
 
-	os.Exit(0)
+// runServer runs the server with the configuration file if any, the
+// configuration is reloaded on SIGHUP. The server drains on SIGINT or
+// SIGTERM, and the second one kills it at once.
+func runServer(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	options := gosnake.DefaultServerOptions
+	if configPath != "" {
+		var err error
+		if options, err = loadConfig(); err != nil {
+			return err
+		}
+	}
+	server := gosnake.NewServer(options)
+	if configPath != "" {
+		go reloadOnHangup(ctx, server)
+	}
+	if console {
+		var shutdown context.CancelFunc
+		ctx, shutdown = context.WithCancel(ctx)
+		defer shutdown()
+		go server.RunConsole(ctx, os.Stdin, os.Stdout, shutdown)
+	}
+	return server.Run(ctx)
+}
+
+// loadConfig loads the configuration file, the flags set explicitly
+// override the file.
+func loadConfig() (*gosnake.ServerOptions, error) {
+	options, err := gosnake.LoadServerOptions(configPath)
+	if err != nil {
+		return nil, err
+	}
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	bindServerFlags(fs, options)
+	flag.Visit(func(f *flag.Flag) {
+		if err == nil && fs.Lookup(f.Name) != nil {
+			err = fs.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func reloadOnHangup(ctx context.Context, server *gosnake.Server) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			options, err := loadConfig()
+			if err == nil {
+				err = server.Reload(options)
+			}
+			if err != nil {
+				gosnake.Logger(gosnake.LogServer).Error("reload failed", "err", err)
+			}
+		}
+	}
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	configPath = filepath.Join(t.TempDir(), "config.json")
+	config := `{
+		"listen_addr": "127.0.0.1:9100",
+		"admin_addr": "127.0.0.1:9200",
+		"drain_seconds": 30,
+		"stats_file": "file.jsonl",
+		"key_file": "file.key"
+	}`
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := flag.CommandLine.Parse([]string{
+		"-drain-seconds", "5", "-stats-file", "flag.jsonl", "-key-file", "flag.key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the flags set explicitly override the file, the others keep it
+	for _, c := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"listen_addr", options.Addr, "127.0.0.1:9100"},
+		{"admin_addr", options.AdminAddr, "127.0.0.1:9200"},
+		{"drain_seconds", options.DrainSeconds, 5},
+		{"stats_file", options.StatsFile, "flag.jsonl"},
+		{"key_file", options.KeyFile, "flag.key"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
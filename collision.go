@@ -0,0 +1,168 @@
+package gosnake
+
+import "sort"
+
+type CollisionRule string
+
+const (
+	CollisionBothDie    CollisionRule = "both_die"
+	CollisionLongerWins CollisionRule = "longer_wins"
+)
+
+func (rule CollisionRule) Valid() bool {
+	return rule == "" || rule == CollisionBothDie || rule == CollisionLongerWins
+}
+
+type DeathCause string
+
+const (
+	DeathWall   DeathCause = "wall"
+	DeathSelf   DeathCause = "self"
+	DeathBody   DeathCause = "body"
+	DeathHeadOn DeathCause = "head_on"
+)
+
+var deathCauses = []DeathCause{DeathWall, DeathSelf, DeathBody, DeathHeadOn}
+
+type death struct {
+	cause  DeathCause
+	killer *Player
+}
+
+type moveIntent struct {
+	player *Player
+	dir    Direction
+	next   Position
+	grows  bool
+}
+
+type moveResult struct {
+	deaths  map[*Player]*death
+	stalled map[*Player]bool
+}
+
+// resolveMoves decides the outcome of all the intended moves of one tick
+// at once, so it does not depend on the order of the players. The players
+// are all the snakes on the board, including the ones which do not move.
+func resolveMoves(intents []*moveIntent, players []*Player, border Taker, rule CollisionRule) *moveResult {
+	sort.Slice(intents, func(i, j int) bool {
+		return intents[i].player.GetID() < intents[j].player.GetID()
+	})
+	result := &moveResult{
+		deaths:  make(map[*Player]*death),
+		stalled: make(map[*Player]bool),
+	}
+	beaten := make(map[*Player]map[*Player]bool)
+	for _, in := range intents {
+		if border.IsTaken(in.next) {
+			result.deaths[in.player] = &death{cause: DeathWall}
+		}
+	}
+	resolveHeadOn(intents, rule, result, beaten)
+
+	// a dead or stalled snake stays where it is, so its tail is not vacated
+	// and the players moving into that tail have to be checked again
+	for changed := true; changed; {
+		changed = false
+		vacated := make(map[*Player]bool)
+		for _, in := range intents {
+			if result.deaths[in.player] == nil && !result.stalled[in.player] && !in.grows {
+				vacated[in.player] = true
+			}
+		}
+		for _, in := range intents {
+			if result.deaths[in.player] != nil || result.stalled[in.player] {
+				continue
+			}
+			for _, other := range players {
+				if !other.IsSnakeTaken(in.next) ||
+					(vacated[other] && in.next == other.GetSnakeTailPos()) {
+					continue
+				}
+				if other == in.player {
+					result.deaths[in.player] = &death{cause: DeathSelf}
+				} else if in.player.IsProtected() || other.IsProtected() {
+					continue
+				} else if beaten[in.player][other] {
+					result.stalled[in.player] = true
+				} else {
+					result.deaths[in.player] = &death{cause: DeathBody, killer: other}
+				}
+				changed = true
+				break
+			}
+		}
+	}
+	return result
+}
+
+// resolveHeadOn handles the heads entering the same cell and the heads
+// swapping their positions.
+func resolveHeadOn(intents []*moveIntent, rule CollisionRule, result *moveResult, beaten map[*Player]map[*Player]bool) {
+	groups := make([][]*moveIntent, 0)
+	cells := make(map[Position]int)
+	for _, in := range intents {
+		if result.deaths[in.player] != nil || in.player.IsProtected() {
+			continue
+		}
+		i, ok := cells[in.next]
+		if !ok {
+			i = len(groups)
+			cells[in.next] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], in)
+	}
+	for i, a := range intents {
+		for _, b := range intents[i+1:] {
+			if a.next == b.player.GetSnakeHeadPos() &&
+				b.next == a.player.GetSnakeHeadPos() &&
+				!a.player.IsProtected() && !b.player.IsProtected() {
+				groups = append(groups, []*moveIntent{a, b})
+			}
+		}
+	}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		var winner *Player
+		if rule == CollisionLongerWins {
+			winner = getLongest(group)
+		}
+		for _, in := range group {
+			if in.player == winner {
+				continue
+			}
+			killer := group[0].player
+			if killer == in.player {
+				killer = group[1].player
+			}
+			if winner != nil {
+				killer = winner
+				if beaten[winner] == nil {
+					beaten[winner] = make(map[*Player]bool)
+				}
+				beaten[winner][in.player] = true
+			}
+			if result.deaths[in.player] == nil {
+				result.deaths[in.player] = &death{cause: DeathHeadOn, killer: killer}
+			}
+		}
+	}
+}
+
+// getLongest returns the player with the strictly longest snake, nil is
+// returned on a tie.
+func getLongest(group []*moveIntent) (longest *Player) {
+	max := -1
+	for _, in := range group {
+		n := in.player.GetSnakeLen()
+		if n > max {
+			max, longest = n, in.player
+		} else if n == max {
+			longest = nil
+		}
+	}
+	return
+}
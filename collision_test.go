@@ -0,0 +1,169 @@
+package gosnake
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestPlayer(id string, dir Direction, body ...Position) *Player {
+	return NewPlayer(nil, id, NewBodySnake(body, dir))
+}
+
+func newTestIntent(player *Player, dir Direction) *moveIntent {
+	return &moveIntent{
+		player: player,
+		dir:    dir,
+		next:   *player.GetSnakeNextHeadPos(dir),
+	}
+}
+
+func TestResolveMovesHeadOn(t *testing.T) {
+	border := NewRecBorder(10, 10, "")
+	cases := []struct {
+		name   string
+		rule   CollisionRule
+		deadA  bool
+		deadB  bool
+		swap   bool
+		stallA bool
+		// c moves into the tail of a, which is vacated only if a moves
+		deadC bool
+	}{
+		{"same cell both die", CollisionBothDie, true, true, false, false, true},
+		{"same cell longer wins", CollisionLongerWins, false, true, false, false, false},
+		{"swap both die", CollisionBothDie, true, true, true, false, true},
+		{"swap longer wins", CollisionLongerWins, false, true, true, true, true},
+	}
+	for _, c := range cases {
+		var a, b *Player
+		if c.swap {
+			a = newTestPlayer("a", DirRight, Position{4, 5}, Position{3, 5}, Position{2, 5})
+			b = newTestPlayer("b", DirLeft, Position{5, 5}, Position{6, 5})
+		} else {
+			a = newTestPlayer("a", DirRight, Position{4, 5}, Position{3, 5}, Position{2, 5})
+			b = newTestPlayer("b", DirLeft, Position{6, 5}, Position{7, 5})
+		}
+		tail := newTestPlayer("c", DirDown, Position{2, 4}, Position{2, 3})
+		intents := []*moveIntent{
+			newTestIntent(b, DirLeft), newTestIntent(a, DirRight), newTestIntent(tail, DirDown),
+		}
+		result := resolveMoves(intents, []*Player{a, b, tail}, border, c.rule)
+		if (result.deaths[a] != nil) != c.deadA || (result.deaths[b] != nil) != c.deadB {
+			t.Errorf("%s: got deaths a=%v b=%v", c.name, result.deaths[a], result.deaths[b])
+		}
+		if result.deaths[b] != nil && result.deaths[b].cause != DeathHeadOn {
+			t.Errorf("%s: got cause %s, want %s", c.name, result.deaths[b].cause, DeathHeadOn)
+		}
+		if result.stalled[a] != c.stallA {
+			t.Errorf("%s: got stalled a=%v, want %v", c.name, result.stalled[a], c.stallA)
+		}
+		if d := result.deaths[tail]; (d != nil) != c.deadC || (d != nil && (d.cause != DeathBody || d.killer != a)) {
+			t.Errorf("%s: got death of c %v, want dead %v by the body of a", c.name, d, c.deadC)
+		}
+	}
+}
+
+func TestResolveMovesTailVacating(t *testing.T) {
+	border := NewRecBorder(10, 10, "")
+	a := newTestPlayer("a", DirRight, Position{3, 5}, Position{2, 5})
+	b := newTestPlayer("b", DirUp, Position{4, 3}, Position{4, 4}, Position{4, 5})
+	c := newTestPlayer("c", DirUp, Position{1, 1}, Position{1, 2})
+
+	// b moves away, so a can follow its tail
+	intents := []*moveIntent{newTestIntent(a, DirRight), newTestIntent(b, DirUp)}
+	result := resolveMoves(intents, []*Player{a, b}, border, CollisionBothDie)
+	if result.deaths[a] != nil {
+		t.Errorf("a died following a moving tail")
+	}
+
+	// b hits the wall and stays, so a crashes into its body
+	b = newTestPlayer("b", DirUp, Position{4, 1}, Position{4, 2})
+	a = newTestPlayer("a", DirUp, Position{4, 3}, Position{4, 4})
+	intents = []*moveIntent{newTestIntent(a, DirUp), newTestIntent(b, DirUp), newTestIntent(c, DirUp)}
+	result = resolveMoves(intents, []*Player{a, b, c}, border, CollisionBothDie)
+	if d := result.deaths[b]; d == nil || d.cause != DeathWall {
+		t.Errorf("b got death %v, want wall", d)
+	}
+	if d := result.deaths[a]; d == nil || d.cause != DeathBody || d.killer != b {
+		t.Errorf("a got death %v, want body by b", d)
+	}
+	if d := result.deaths[c]; d == nil || d.cause != DeathWall {
+		t.Errorf("c got death %v, want wall", d)
+	}
+}
+
+func TestPlayersEatTieBreak(t *testing.T) {
+	food := Position{5, 5}
+	now := time.Now()
+	cases := []struct {
+		name       string
+		lenA, lenB int
+		joinA      time.Time
+		joinB      time.Time
+		idA, idB   string
+		want       string
+	}{
+		{"shortest", 3, 2, now, now.Add(-time.Second), "a", "b", "b"},
+		{"earliest join", 2, 2, now, now.Add(time.Second), "a", "b", "a"},
+		{"earliest join second", 2, 2, now.Add(time.Second), now, "a", "b", "b"},
+		{"id", 2, 2, now, now, "b", "a", "a"},
+	}
+	for _, c := range cases {
+		for _, reversed := range []bool{false, true} {
+			// both heads reach the food from the left and from above
+			bodyA := []Position{food, {4, 5}, {3, 5}}[:c.lenA]
+			bodyB := []Position{food, {5, 4}, {5, 3}}[:c.lenB]
+			a := newTestPlayer(c.idA, DirRight, bodyA...)
+			b := newTestPlayer(c.idB, DirDown, bodyB...)
+			a.createdAt, b.createdAt = c.joinA, c.joinB
+			moved := []*Player{a, b}
+			if reversed {
+				moved = []*Player{b, a}
+			}
+			room := NewRoom(0, DefaultServerOptions.RoomOptions, func([]byte, *net.UDPAddr, int) {})
+			room.Init()
+			room.food = &FoodManager{foods: []*Food{NewFood(food)}}
+
+			if a.Before(b) == b.Before(a) {
+				t.Errorf("%s: got a before b %v both ways", c.name, a.Before(b))
+			}
+			room.playersEat(moved)
+			lens := map[*Player]int{a: c.lenA, b: c.lenB}
+			winner := ""
+			for _, p := range []*Player{a, b} {
+				if p.GetSnakeLen() > lens[p] {
+					if winner != "" {
+						t.Fatalf("%s: both snakes ate the food", c.name)
+					}
+					winner = p.GetID()
+				}
+			}
+			if winner != c.want {
+				t.Errorf("%s (reversed %v): got winner %q, want %q", c.name, reversed, winner, c.want)
+			}
+			if room.food.IsTaken(food) {
+				t.Errorf("%s: the food is not eaten", c.name)
+			}
+		}
+	}
+}
+
+func TestPlayersEatGolden(t *testing.T) {
+	food := Position{5, 5}
+	for _, golden := range []bool{false, true} {
+		player := newTestPlayer("a", DirRight, food, Position{4, 5})
+		room := NewRoom(0, DefaultServerOptions.RoomOptions, func([]byte, *net.UDPAddr, int) {})
+		room.Init()
+		room.food = &FoodManager{foods: []*Food{{pos: food, golden: golden}}}
+		room.playersEat([]*Player{player})
+		want := 1.0
+		if golden {
+			want = goldenFoodSpeed
+		}
+		if player.GetSnakeLen() != 3 || player.GetSpeed() != want {
+			t.Errorf("golden %v: got length %d and speed %v, want 3 and %v",
+				golden, player.GetSnakeLen(), player.GetSpeed(), want)
+		}
+	}
+}
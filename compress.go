@@ -47,3 +47,17 @@ func (cl *CompressLayer) getPosOffset(pos Position) (nbyte, nbit int) {
 	nbit = 8 - (offset % 8) - 1
 	return
 }
+
+// encode writes the size and the takes of the layer, the symbol is chosen
+// by the client.
+func (cl *CompressLayer) encode(w *wireWriter) {
+	w.Uint(uint64(cl.W))
+	w.Uint(uint64(cl.H))
+	w.Bytes(cl.Takes)
+}
+
+func (cl *CompressLayer) decode(r *wireReader) {
+	cl.W = int(r.Uint())
+	cl.H = int(r.Uint())
+	cl.Takes = r.Bytes()
+}
@@ -0,0 +1,210 @@
+package gosnake
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	minBorderSize   = 8
+	maxBorderSize   = 256
+	maxDatagramSize = 65507
+)
+
+// serverConfig is the layout of the configuration file, the rooms are
+// decoded on top of the room options of the file.
+type serverConfig struct {
+	*ServerOptions
+	Rooms []json.RawMessage `json:"rooms"`
+}
+
+// LoadServerOptions reads the server options from the JSON file, the
+// fields missing in the file keep the default values.
+func LoadServerOptions(path string) (*ServerOptions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	options := DefaultServerOptions.clone()
+	config := &serverConfig{ServerOptions: options}
+	if err = decodeConfig(data, config); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	options.Rooms = nil
+	for i, raw := range config.Rooms {
+		room := options.RoomOptions.clone()
+		if err = decodeConfig(raw, room); err != nil {
+			return nil, fmt.Errorf("config %s: room %d: %w", path, i, err)
+		}
+		options.Rooms = append(options.Rooms, room)
+	}
+	if err = options.Validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return options, nil
+}
+
+func decodeConfig(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Validate reports the first invalid option of the server and its rooms.
+func (options *ServerOptions) Validate() error {
+	if options.Addr == "" {
+		return errors.New("listen_addr is empty")
+	}
+	if options.MaxDatagramSize < minDatagramSize || options.MaxDatagramSize > maxDatagramSize {
+		return fmt.Errorf(
+			"max_datagram_size %d is out of range [%d, %d]",
+			options.MaxDatagramSize, minDatagramSize, maxDatagramSize,
+		)
+	}
+	if options.DrainSeconds < 0 {
+		return fmt.Errorf("drain_seconds %d is negative", options.DrainSeconds)
+	}
+	if options.RoomOptions == nil {
+		return errors.New("room_options is missing")
+	}
+	if err := options.RoomOptions.Validate(); err != nil {
+		return fmt.Errorf("room_options: %w", err)
+	}
+	rooms := options.GetRooms()
+	if len(rooms) > options.MaxRooms {
+		return fmt.Errorf(
+			"%d rooms are more than max_rooms %d", len(rooms), options.MaxRooms,
+		)
+	}
+	inviteCodes := make(map[string]int, len(rooms))
+	for i, room := range rooms {
+		if err := room.Validate(); err != nil {
+			return fmt.Errorf("room %d: %w", i, err)
+		}
+		if room.InviteCode == "" {
+			continue
+		}
+		if j, ok := inviteCodes[room.InviteCode]; ok {
+			return fmt.Errorf("room %d: invite_code is used by room %d", i, j)
+		}
+		inviteCodes[room.InviteCode] = i
+	}
+	return nil
+}
+
+// GetRooms returns the options of the rooms started with the server, they
+// are room_size copies of the room options unless the rooms are listed.
+func (options *ServerOptions) GetRooms() []*RoomOptions {
+	if len(options.Rooms) > 0 {
+		return options.Rooms
+	}
+	rooms := make([]*RoomOptions, options.RoomSize)
+	for i := range rooms {
+		rooms[i] = options.RoomOptions
+	}
+	return rooms
+}
+
+func (options *ServerOptions) clone() *ServerOptions {
+	c := *options
+	c.RoomOptions = options.RoomOptions.clone()
+	c.Rooms = make([]*RoomOptions, len(options.Rooms))
+	for i, room := range options.Rooms {
+		c.Rooms[i] = room.clone()
+	}
+	return &c
+}
+
+// Validate reports the first invalid option of the room.
+func (options *RoomOptions) Validate() error {
+	if options.BorderWidth < minBorderSize || options.BorderWidth > maxBorderSize {
+		return fmt.Errorf(
+			"border_width %d is out of range [%d, %d]",
+			options.BorderWidth, minBorderSize, maxBorderSize,
+		)
+	}
+	if options.BorderHeight < minBorderSize || options.BorderHeight > maxBorderSize {
+		return fmt.Errorf(
+			"border_height %d is out of range [%d, %d]",
+			options.BorderHeight, minBorderSize, maxBorderSize,
+		)
+	}
+	if options.AutoMoveIntervalMS <= 0 {
+		return fmt.Errorf(
+			"auto_move_interval_ms %d is not positive", options.AutoMoveIntervalMS,
+		)
+	}
+	// the snakes move at most one step per tick, a slower tick caps the
+	// boost and the golden food at the normal speed
+	if options.TickIntervalMS <= 0 || options.TickIntervalMS >= options.AutoMoveIntervalMS {
+		return fmt.Errorf(
+			"tick_interval_ms %d is out of range (0, auto_move_interval_ms %d)",
+			options.TickIntervalMS, options.AutoMoveIntervalMS,
+		)
+	}
+	if options.GoldenFoodPercent < 0 || options.GoldenFoodPercent > 100 {
+		return fmt.Errorf(
+			"golden_food_percent %d is out of range [0, 100]", options.GoldenFoodPercent,
+		)
+	}
+	if options.PlayerSize <= 0 {
+		return fmt.Errorf("player_size %d is not positive", options.PlayerSize)
+	}
+	// a snake spawned without a runway may die on its first step
+	if options.SpawnRunway < 1 {
+		return fmt.Errorf("spawn_runway %d is not positive", options.SpawnRunway)
+	}
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"boost_cost_steps", options.BoostCostSteps},
+		{"food_num", options.FoodNum},
+		{"food_per_player", options.FoodPerPlayer},
+		{"spawn_protection_ms", options.SpawnProtectionMS},
+		{"max_client_bandwidth", options.MaxClientBandwidth},
+	} {
+		if field.value < 0 {
+			return fmt.Errorf("%s %d is negative", field.name, field.value)
+		}
+	}
+	if !options.FoodSpawn.Valid() {
+		return fmt.Errorf("food_spawn %q is unknown", options.FoodSpawn)
+	}
+	if !options.CollisionRule.Valid() {
+		return fmt.Errorf("collision_rule %q is unknown", options.CollisionRule)
+	}
+	if size := options.getMaxSnapshotSize(); size > maxFrameSize {
+		return fmt.Errorf(
+			"snapshot of %dx%d with %d players may take %d bytes, more than %d",
+			options.BorderWidth, options.BorderHeight, options.PlayerSize, size, maxFrameSize,
+		)
+	}
+	limit := options.getPosLimit()
+	for _, point := range options.SpawnPoints {
+		if !limit.Contains(point) {
+			return fmt.Errorf(
+				"spawn point (%d, %d) is out of the border, x in [%d, %d], y in [%d, %d]",
+				point.X, point.Y, limit.MinX, limit.MaxX, limit.MinY, limit.MaxY,
+			)
+		}
+	}
+	return nil
+}
+
+// getPosLimit returns the limit of the positions inside the border.
+func (options *RoomOptions) getPosLimit() Limit {
+	return Limit{
+		MinX: 1, MaxX: options.BorderWidth - 2,
+		MinY: 1, MaxY: options.BorderHeight - 2,
+	}
+}
+
+func (options *RoomOptions) clone() *RoomOptions {
+	c := *options
+	c.SpawnPoints = append([]Position(nil), options.SpawnPoints...)
+	return &c
+}
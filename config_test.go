@@ -0,0 +1,72 @@
+package gosnake
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadServerOptions(t *testing.T) {
+	path := writeConfig(t, `{
+		"listen_addr": "127.0.0.1:9100",
+		"room_options": {"player_size": 3},
+		"rooms": [
+			{"border_width": 16, "collision_rule": "longer_wins"},
+			{"spawn_points": [{"x": 4, "y": 4}]}
+		]
+	}`)
+	options, err := LoadServerOptions(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if options.Addr != "127.0.0.1:9100" {
+		t.Errorf("got addr %s", options.Addr)
+	}
+	rooms := options.GetRooms()
+	if len(rooms) != 2 {
+		t.Fatalf("got %d rooms, want 2", len(rooms))
+	}
+	// the rooms are decoded on top of the room options of the file
+	if rooms[0].BorderWidth != 16 || rooms[0].PlayerSize != 3 ||
+		rooms[0].CollisionRule != CollisionLongerWins {
+		t.Errorf("got room 0 %+v", rooms[0])
+	}
+	if rooms[1].BorderWidth != DefaultServerOptions.RoomOptions.BorderWidth ||
+		len(rooms[1].SpawnPoints) != 1 {
+		t.Errorf("got room 1 %+v", rooms[1])
+	}
+	if DefaultServerOptions.RoomOptions.PlayerSize == 3 {
+		t.Errorf("default options are changed")
+	}
+}
+
+func TestLoadServerOptionsInvalid(t *testing.T) {
+	for content, want := range map[string]string{
+		`{"rooms": [{}, {"border_width": 4}]}`:                    "room 1: border_width 4",
+		`{"room_options": {"food_spawn": "corners"}}`:             `food_spawn "corners"`,
+		`{"rooms": [{"spawn_points": [{"x": 40}]}]}`:              "spawn point (40, 0)",
+		`{"max_rooms": 1, "room_size": 2}`:                        "more than max_rooms",
+		`{"listen_address": "127.0.0.1:9100"}`:                    "unknown field",
+		`{"rooms": [{"player_size": 0}]}`:                         "player_size 0",
+		`{"rooms": [{"spawn_runway": 0}]}`:                        "spawn_runway 0",
+		`{"rooms": [{"tick_interval_ms": 300}]}`:                  "tick_interval_ms 300",
+		`{"rooms": [{"tick_interval_ms": 0}]}`:                    "tick_interval_ms 0",
+		`{"room_options": {"golden_food_percent": 101}}`:          "golden_food_percent 101",
+		`{"rooms": [{"player_size": 1000}]}`:                      "with 1000 players",
+		`{"rooms": [{"invite_code": "A"}, {"invite_code": "A"}]}`: "used by room 0",
+	} {
+		_, err := LoadServerOptions(writeConfig(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want %q", content, err, want)
+		}
+	}
+}
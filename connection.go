@@ -0,0 +1,98 @@
+package gosnake
+
+import (
+	"fmt"
+	"time"
+)
+
+const maxJoinBackoff = 8 * time.Second
+
+type connState int
+
+const (
+	connJoining connState = iota
+	connJoined
+	connReconnecting
+)
+
+// Connection tracks whether the server keeps responding, and schedules the
+// join retries with exponential backoff after the server is lost.
+type Connection struct {
+	state       connState
+	lastRecv    time.Time
+	lostAt      time.Time
+	nextJoin    time.Time
+	backoff     time.Duration
+	attempts    int
+	lostTimeout time.Duration
+	minBackoff  time.Duration
+	giveUp      time.Duration
+}
+
+func NewConnection(lostTimeout, backoff, giveUp time.Duration) *Connection {
+	now := time.Now()
+	return &Connection{
+		state:       connJoining,
+		lostAt:      now,
+		nextJoin:    now,
+		backoff:     backoff,
+		lostTimeout: lostTimeout,
+		minBackoff:  backoff,
+		giveUp:      giveUp,
+	}
+}
+
+// Received marks the server as responding.
+func (c *Connection) Received(now time.Time) {
+	c.lastRecv = now
+	c.state = connJoined
+	c.attempts = 0
+}
+
+// Check reports whether a join should be sent now, err is returned when
+// the server has not responded for longer than the give up limit.
+func (c *Connection) Check(now time.Time) (join bool, err error) {
+	if c.state == connJoined {
+		if now.Sub(c.lastRecv) <= c.lostTimeout {
+			return
+		}
+		c.state = connReconnecting
+		c.lostAt = c.lastRecv
+		c.nextJoin = now
+		c.backoff = c.minBackoff
+	}
+	if now.Sub(c.lostAt) > c.giveUp {
+		err = fmt.Errorf(
+			"connection lost: the server did not respond for %s",
+			c.giveUp,
+		)
+		return
+	}
+	if now.Before(c.nextJoin) {
+		return
+	}
+	c.attempts += 1
+	c.nextJoin = now.Add(c.backoff)
+	c.backoff *= 2
+	if c.backoff > maxJoinBackoff {
+		c.backoff = maxJoinBackoff
+	}
+	return true, nil
+}
+
+func (c *Connection) IsJoined() bool {
+	return c.state == connJoined
+}
+
+func (c *Connection) GetStatus() string {
+	switch c.state {
+	case connJoining:
+		return fmt.Sprintf(" * Joining the server (attempt %d)...", c.attempts)
+	case connReconnecting:
+		return fmt.Sprintf(
+			" * \033[1;41;37m Connection lost, reconnecting (attempt %d)... \033[0m",
+			c.attempts,
+		)
+	}
+	return ""
+}
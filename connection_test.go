@@ -0,0 +1,36 @@
+package gosnake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnectionReconnect(t *testing.T) {
+	c := NewConnection(time.Second, 100*time.Millisecond, 10*time.Second)
+	now := time.Now()
+	if join, _ := c.Check(now); !join {
+		t.Fatalf("join is not sent at start")
+	}
+	c.Received(now)
+	if join, _ := c.Check(now.Add(time.Second)); join {
+		t.Errorf("join is sent while the server responds")
+	}
+
+	// the server is lost, the joins are retried with backoff
+	var joins []time.Duration
+	for d := 1100 * time.Millisecond; d < 3*time.Second; d += 10 * time.Millisecond {
+		join, err := c.Check(now.Add(d))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if join {
+			joins = append(joins, d)
+		}
+	}
+	if len(joins) != 5 {
+		t.Errorf("got joins at %v, want 5 joins", joins)
+	}
+	if _, err := c.Check(now.Add(11 * time.Second)); err == nil {
+		t.Errorf("connection is not given up")
+	}
+}
@@ -0,0 +1,322 @@
+package gosnake
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const consolePrompt = "> "
+
+var errConsoleUsage = errors.New("invalid arguments")
+
+// consoleCommand is a command of the operator console, the arguments
+// exclude the name of the command.
+type consoleCommand struct {
+	usage string
+	help  string
+	run   func(c *console, args []string) error
+}
+
+var consoleCommands map[string]*consoleCommand
+
+func init() {
+	// help reads the map, it is set later to avoid the initialization cycle
+	consoleCommands = map[string]*consoleCommand{
+		"help":     {"help", "list the commands", nil},
+		"rooms":    {"rooms", "list the rooms", (*console).rooms},
+		"players":  {"players <room>", "list the players of the room", (*console).players},
+		"kick":     {"kick <room> <player> [reason]", "kick the player", (*console).kick},
+		"ban":      {"ban <room> <player> [duration]", "ban the address of the player and kick it", (*console).ban},
+		"say":      {"say <room|all> <message>", "send the notice to the players", (*console).say},
+		"speed":    {"speed <room> <ms>", "set the interval the snakes move by themselves", (*console).speed},
+		"pause":    {"pause <room>", "pause the snakes of the room", (*console).pause},
+		"resume":   {"resume <room>", "resume the snakes of the room", (*console).resume},
+		"close":    {"close <room>", "close the room and remove its players", (*console).close},
+		"shutdown": {"shutdown", "drain the players and stop the server", (*console).shutdown},
+	}
+	consoleCommands["help"].run = (*console).help
+}
+
+// RunConsole reads the commands of the operator line by line until the
+// input ends or the context is done. The commands change the rooms in
+// their goroutines like the players do, shutdown is called by the
+// shutdown command.
+func (s *Server) RunConsole(ctx context.Context, in io.Reader, out io.Writer, shutdown func()) {
+	c := &console{server: s, out: out, stop: shutdown}
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	fmt.Fprint(out, consolePrompt)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			c.exec(editLine(line))
+			fmt.Fprint(out, consolePrompt)
+		}
+	}
+}
+
+type console struct {
+	server *Server
+	out    io.Writer
+	stop   func()
+}
+
+func (c *console) exec(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	cmd := consoleCommands[fields[0]]
+	if cmd == nil {
+		fmt.Fprintf(c.out, "unknown command %q, type help for the commands\n", fields[0])
+		return
+	}
+	err := cmd.run(c, fields[1:])
+	if err == errConsoleUsage {
+		fmt.Fprintf(c.out, "usage: %s\n", cmd.usage)
+	} else if err != nil {
+		fmt.Fprintf(c.out, "%s: %v\n", fields[0], err)
+	}
+}
+
+func (c *console) help(args []string) error {
+	names := make([]string, 0, len(consoleCommands))
+	for name := range consoleCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", consoleCommands[name].usage, consoleCommands[name].help)
+	}
+	return tw.Flush()
+}
+
+func (c *console) rooms(args []string) error {
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPLAYERS\tSPEED\tTICK\tSTATE")
+	for _, room := range c.server.getRooms() {
+		status, ok := room.GetStatus()
+		if !ok {
+			continue
+		}
+		state := "open"
+		if status.Options.IsPrivate() {
+			state = "private"
+		}
+		if status.Paused {
+			state += ",paused"
+		}
+		if status.Retired {
+			state += ",retired"
+		}
+		fmt.Fprintf(
+			tw, "%d\t%d/%d\t%dms\t%d\t%s\n",
+			status.ID, len(status.Players), status.Options.PlayerSize,
+			status.Options.AutoMoveIntervalMS, status.Tick, state,
+		)
+	}
+	return tw.Flush()
+}
+
+func (c *console) players(args []string) error {
+	room, err := c.room(args, 1)
+	if err != nil {
+		return err
+	}
+	status, ok := room.GetStatus()
+	if !ok {
+		return errRoomStopped
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSCORE\tLENGTH\tLATENCY\tSTATE")
+	for _, player := range status.Players {
+		state := "playing"
+		if player.Over {
+			state = "over"
+		} else if player.Pause {
+			state = "paused"
+		}
+		fmt.Fprintf(
+			tw, "%s\t%d\t%d\t%dms\t%s\n",
+			player.ID, player.Score, player.Length, player.LatencyMS, state,
+		)
+	}
+	return tw.Flush()
+}
+
+func (c *console) kick(args []string) error {
+	if len(args) < 2 {
+		return errConsoleUsage
+	}
+	room, err := c.room(args[:1], 1)
+	if err != nil {
+		return err
+	}
+	reason := "kicked by the server"
+	if len(args) > 2 {
+		reason = strings.Join(args[2:], " ")
+	}
+	return room.Kick(args[1], reason)
+}
+
+func (c *console) ban(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errConsoleUsage
+	}
+	room, err := c.room(args[:1], 1)
+	if err != nil {
+		return err
+	}
+	d := addrBanDuration
+	if len(args) == 3 {
+		if d, err = time.ParseDuration(args[2]); err != nil || d <= 0 {
+			return errors.New("invalid duration")
+		}
+	}
+	return c.server.banPlayer(room, args[1], d)
+}
+
+func (c *console) say(args []string) error {
+	if len(args) < 2 {
+		return errConsoleUsage
+	}
+	message := strings.Join(args[1:], " ")
+	if len(message) > maxNoticeLength {
+		return fmt.Errorf("message is longer than %d", maxNoticeLength)
+	}
+	rooms := c.server.getRooms()
+	if args[0] != "all" {
+		room, err := c.room(args[:1], 1)
+		if err != nil {
+			return err
+		}
+		rooms = []*Room{room}
+	}
+	for _, room := range rooms {
+		room.Broadcast(message)
+	}
+	return nil
+}
+
+func (c *console) speed(args []string) error {
+	if len(args) != 2 {
+		return errConsoleUsage
+	}
+	room, err := c.room(args[:1], 1)
+	if err != nil {
+		return err
+	}
+	ms, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errConsoleUsage
+	}
+	return room.SetSpeed(ms)
+}
+
+func (c *console) pause(args []string) error {
+	return c.setPaused(args, true)
+}
+
+func (c *console) resume(args []string) error {
+	return c.setPaused(args, false)
+}
+
+func (c *console) setPaused(args []string, paused bool) error {
+	room, err := c.room(args, 1)
+	if err != nil {
+		return err
+	}
+	if !room.SetPaused(paused) {
+		return errRoomStopped
+	}
+	return nil
+}
+
+func (c *console) close(args []string) error {
+	room, err := c.room(args, 1)
+	if err != nil {
+		return err
+	}
+	logger(LogServer).Info("room closed by console", "room", room.GetID())
+	if !room.Close(errRoomRetired.Error()) {
+		return errRoomStopped
+	}
+	return nil
+}
+
+func (c *console) shutdown(args []string) error {
+	if len(args) != 0 {
+		return errConsoleUsage
+	}
+	fmt.Fprintln(c.out, "shutting down")
+	c.stop()
+	return nil
+}
+
+// room returns the room of the first argument, the arguments must be n.
+func (c *console) room(args []string, n int) (*Room, error) {
+	if len(args) != n {
+		return nil, errConsoleUsage
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, errConsoleUsage
+	}
+	room := c.server.getRoom(id)
+	if room == nil {
+		return nil, errRoomNotFound
+	}
+	return room, nil
+}
+
+// editLine applies the erase characters left in the line, such as the
+// backspaces of the terminal not in the canonical mode.
+func editLine(line string) string {
+	buf := make([]rune, 0, len(line))
+	for _, r := range line {
+		switch r {
+		case '\b', 0x7f:
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+		case 0x15: // ctrl-u
+			buf = buf[:0]
+		case 0x17: // ctrl-w
+			for len(buf) > 0 && buf[len(buf)-1] == ' ' {
+				buf = buf[:len(buf)-1]
+			}
+			for len(buf) > 0 && buf[len(buf)-1] != ' ' {
+				buf = buf[:len(buf)-1]
+			}
+		default:
+			if r >= ' ' || r == '\t' {
+				buf = append(buf, r)
+			}
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,83 @@
+package gosnake
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsole(t *testing.T) {
+	options := DefaultServerOptions.clone()
+	options.Addr = "127.0.0.1:0"
+	// the room with an invite code only is private as well
+	invited := options.RoomOptions.clone()
+	invited.InviteCode = "ABC234"
+	options.Rooms = []*RoomOptions{invited, options.RoomOptions.clone()}
+	options.RoomSize = len(options.Rooms)
+	server := NewServer(options)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx)
+	for len(server.getRooms()) < options.RoomSize {
+		time.Sleep(time.Millisecond)
+	}
+	closed, changed := server.getRoom(0), server.getRoom(1)
+
+	in := strings.Join([]string{
+		"rooms",
+		"speed 1 120",
+		"speed 1 -5",
+		"pause 1",
+		"kick 1 127.0.0.1:1",
+		"ban 1",
+		"say 7 hello",
+		"close 0",
+		"unknown",
+		"shutdown",
+	}, "\n")
+	var out bytes.Buffer
+	stopped := false
+	server.RunConsole(ctx, strings.NewReader(in), &out, func() { stopped = true })
+
+	for _, want := range []string{
+		"ID  PLAYERS  SPEED  TICK  STATE",
+		"1   0/5      300ms  ",
+		"private\n",
+		"speed: auto_move_interval_ms -5 is not positive",
+		"kick: player not found",
+		"usage: ban <room> <player> [duration]",
+		"say: room not found",
+		`unknown command "unknown"`,
+		"shutting down",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+	if !stopped {
+		t.Error("shutdown is not called")
+	}
+	status, _ := changed.GetStatus()
+	if status.Options.AutoMoveIntervalMS != 120 || !status.Paused {
+		t.Errorf("got speed %d and paused %v, want 120 and true", status.Options.AutoMoveIntervalMS, status.Paused)
+	}
+	if !closed.IsRetired() {
+		t.Error("room 0 is not closed")
+	}
+}
+
+func TestEditLine(t *testing.T) {
+	for in, want := range map[string]string{
+		"rooms":             "rooms",
+		"roomx\b":           "room",
+		"kick 1\x7f2":       "kick 2",
+		"say all hi\x15say": "say",
+		"say all hi\x17yo":  "say all yo",
+	} {
+		if got := editLine(in); got != want {
+			t.Errorf("editLine(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
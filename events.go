@@ -0,0 +1,192 @@
+package gosnake
+
+import (
+	"fmt"
+)
+
+const (
+	// maxEventLog is the number of the events kept by the room until the
+	// players ack them, the older ones are lost for the slow players.
+	maxEventLog = 64
+	// maxSceneEvents is the number of the unacked events sent in one
+	// snapshot.
+	maxSceneEvents = 16
+	feedHistory    = 6
+)
+
+type EventKind string
+
+const (
+	EventJoin  EventKind = "join"
+	EventLeave EventKind = "leave"
+	EventDeath EventKind = "death"
+	EventRound EventKind = "round"
+	// EventPickup is sent when a snake eats golden food, the plain food
+	// is too common for the feed.
+	EventPickup EventKind = "pickup"
+)
+
+// GameEvent is a discrete event of the room, it is sent with the
+// snapshots until the player acks its sequence.
+type GameEvent struct {
+	Seq      uint32
+	Kind     EventKind
+	PlayerID string
+	// OtherID is the killer of the death event.
+	OtherID string
+	Cause   DeathCause
+	Score   int
+}
+
+func (event *GameEvent) encode(w *wireWriter) {
+	w.Uint(uint64(event.Seq))
+	w.String(string(event.Kind))
+	w.String(event.PlayerID)
+	w.String(event.OtherID)
+	w.String(string(event.Cause))
+	w.Int(int64(event.Score))
+}
+
+func (event *GameEvent) decode(r *wireReader) {
+	event.Seq = uint32(r.Uint())
+	event.Kind = EventKind(r.String())
+	event.PlayerID = r.String()
+	event.OtherID = r.String()
+	event.Cause = DeathCause(r.String())
+	event.Score = int(r.Int())
+}
+
+// addEvent appends the event to the log of the room, the log is trimmed
+// to the max event log.
+func (room *Room) addEvent(event *GameEvent) {
+	room.eventSeq++
+	event.Seq = room.eventSeq
+	room.events = append(room.events, event)
+	if len(room.events) > maxEventLog {
+		room.events = append(room.events[:0], room.events[len(room.events)-maxEventLog:]...)
+	}
+	room.dirty = true
+}
+
+// getPlayerEvents returns the events not acked by the player yet.
+func (room *Room) getPlayerEvents(player *Player) []*GameEvent {
+	ack := player.GetEventAck()
+	i := len(room.events)
+	for i > 0 && room.events[i-1].Seq > ack {
+		i--
+	}
+	events := room.events[i:]
+	if len(events) > maxSceneEvents {
+		events = events[:maxSceneEvents]
+	}
+	// the log is trimmed in place while the writer encodes the snapshot
+	return append([]*GameEvent(nil), events...)
+}
+
+// fitSceneEvents encodes the snapshot, the last events are left out until
+// it fits in one datagram of the size. The events left out are sent with
+// the next snapshots since they are not acked, one event is always sent so
+// that the events go on.
+func fitSceneEvents(scene *SceneData, datagramSize int) []byte {
+	data := (&ServerData{Scene: scene}).Encode()
+	over := len(data) - getScenePayloadSize(datagramSize)
+	n := len(scene.Events)
+	for over > 0 && n > 1 {
+		n--
+		w := &wireWriter{}
+		scene.Events[n].encode(w)
+		over -= len(w.buf)
+	}
+	if n == len(scene.Events) {
+		return data
+	}
+	scene.Events = scene.Events[:n]
+	return (&ServerData{Scene: scene}).Encode()
+}
+
+// playerAckEvents drops the events up to the sequence for the player.
+func (room *Room) playerAckEvents(player *Player, seq uint32) {
+	if seq <= room.eventSeq {
+		player.AckEvents(seq)
+	}
+}
+
+// GetEventAck returns the sequence of the last event received by the
+// player.
+func (player *Player) GetEventAck() uint32 {
+	return player.eventAck
+}
+
+// AckEvents records the sequence of the last event received by the
+// player, the stale acks are ignored.
+func (player *Player) AckEvents(seq uint32) {
+	if seq > player.eventAck {
+		player.eventAck = seq
+	}
+}
+
+// handleEvents shows the new events of the snapshot in the feed, and
+// acks them so that the server stops resending them.
+func (client *Client) handleEvents(events []*GameEvent) {
+	if len(events) == 0 {
+		return
+	}
+	for _, event := range events {
+		if event.Seq <= client.eventSeq {
+			continue
+		}
+		client.eventSeq = event.Seq
+		client.feed = append(client.feed, event)
+	}
+	if len(client.feed) > feedHistory {
+		client.feed = client.feed[len(client.feed)-feedHistory:]
+	}
+	// the acks may be lost, so the resent events are acked again
+	client.sendData(&ClientData{
+		RoomID: client.roomID,
+		CMD:    CMDEventAck,
+		Seq:    client.eventSeq,
+	})
+}
+
+// getFeedTexts returns the event feed shown next to the board.
+func (client *Client) getFeedTexts(playerID string) (texts Lines) {
+	texts = append(texts, "\033[1m events\033[0m")
+	for _, event := range client.feed {
+		texts = append(texts, " "+client.getEventText(playerID, event))
+	}
+	return
+}
+
+func (client *Client) getEventText(playerID string, event *GameEvent) string {
+	player := client.getPlayerName(playerID, event.PlayerID)
+	switch event.Kind {
+	case EventJoin:
+		return player + " joined"
+	case EventLeave:
+		return player + " left"
+	case EventRound:
+		return player + " started a new round"
+	case EventPickup:
+		return player + " ate golden food"
+	case EventDeath:
+		killer := ""
+		if event.OtherID != "" {
+			killer = client.getPlayerName(playerID, event.OtherID)
+		}
+		return fmt.Sprintf(
+			"%s %s (score %d)", player, describeDeath(event.Cause, killer), event.Score,
+		)
+	}
+	return player + " " + string(event.Kind)
+}
+
+// getPlayerName returns the id of the player with the color of its snake.
+func (client *Client) getPlayerName(playerID, id string) string {
+	color := IfStr(
+		id == playerID,
+		client.options.PlayerSnakeColor,
+		GetSnakeColor(id, client.options.SnakeColors),
+	)
+	return fmt.Sprintf("\033[%sm  \033[0m %s", color, IfStr(id == playerID, "you", id))
+}
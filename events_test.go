@@ -0,0 +1,114 @@
+package gosnake
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPlayerEvents(t *testing.T) {
+	room := NewRoom(0, DefaultServerOptions.RoomOptions, func([]byte, *net.UDPAddr, int) {})
+	room.Init()
+	a := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	b := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2}
+	send := func(addr *net.UDPAddr, cliData *ClientData) {
+		room.handleData(&RoomData{Sender: addr, ClientData: cliData})
+	}
+	kinds := func(events []*GameEvent) (s string) {
+		for _, event := range events {
+			s += string(event.Kind) + ":" + event.PlayerID + " "
+		}
+		return
+	}
+
+	send(a, &ClientData{CMD: CMDJoin})
+	send(b, &ClientData{CMD: CMDJoin})
+	playerA, playerB := room.players[a.String()], room.players[b.String()]
+	if got := kinds(room.getPlayerEvents(playerA)); got != "join:127.0.0.1:1 join:127.0.0.1:2 " {
+		t.Errorf("got events %q for the first player", got)
+	}
+	// the events before the join are not sent
+	if got := kinds(room.getPlayerEvents(playerB)); got != "join:127.0.0.1:2 " {
+		t.Errorf("got events %q for the second player", got)
+	}
+
+	send(a, &ClientData{CMD: CMDEventAck, Seq: 2})
+	send(a, &ClientData{CMD: CMDEventAck, Seq: 1})
+	if got := room.getPlayerEvents(playerA); len(got) != 0 {
+		t.Errorf("got acked events %q", kinds(got))
+	}
+	send(a, &ClientData{CMD: CMDEventAck, Seq: 99})
+	if playerA.GetEventAck() != 2 {
+		t.Errorf("got ack %d, want 2", playerA.GetEventAck())
+	}
+
+	send(b, &ClientData{CMD: CMDQuit})
+	if got := kinds(room.getPlayerEvents(playerA)); got != "leave:127.0.0.1:2 " {
+		t.Errorf("got events %q after the quit", got)
+	}
+
+	// the golden food is in the feed, the plain food is not
+	for _, golden := range []bool{false, true} {
+		room.food = &FoodManager{foods: []*Food{{pos: playerA.GetSnakeHeadPos(), golden: golden}}}
+		room.playersEat([]*Player{playerA})
+	}
+	if got := kinds(room.getPlayerEvents(playerA)); got != "leave:127.0.0.1:2 pickup:127.0.0.1:1 " {
+		t.Errorf("got events %q after the food", got)
+	}
+
+	for i := 0; i < 2*maxEventLog; i++ {
+		room.addEvent(&GameEvent{Kind: EventRound})
+	}
+	events := room.getPlayerEvents(playerA)
+	if len(room.events) != maxEventLog || len(events) != maxSceneEvents {
+		t.Errorf("got %d events in the log and %d in the scene", len(room.events), len(events))
+	}
+	if events[0].Seq != room.eventSeq-maxEventLog+1 {
+		t.Errorf("got the first event %d, want %d", events[0].Seq, room.eventSeq-maxEventLog+1)
+	}
+}
+
+func TestSceneSize(t *testing.T) {
+	room := NewRoom(0, DefaultServerOptions.RoomOptions, func([]byte, *net.UDPAddr, int) {})
+	room.Init()
+	players := make([]*Player, 0, 5)
+	for i := 0; i < 5; i++ {
+		// the longest player ids
+		addr := &net.UDPAddr{IP: net.IPv4(255, 255, 255, byte(200+i)), Port: 65500 + i}
+		room.handleData(&RoomData{Sender: addr, ClientData: &ClientData{CMD: CMDJoin}})
+		players = append(players, room.players[addr.String()])
+	}
+	scene := room.getPlayerSceneData(players[0])
+	scene.Events = nil
+	// a regression of the encoding shows up here first
+	if size := len((&ServerData{Scene: scene}).Encode()); size > 500 {
+		t.Errorf("got snapshot of 5 players of %d bytes, want 500 at most", size)
+	}
+
+	for i := 0; i < maxEventLog; i++ {
+		room.addEvent(&GameEvent{
+			Kind: EventDeath, PlayerID: players[1].GetID(), OtherID: players[2].GetID(),
+			Cause: DeathHeadOn, Score: 1000,
+		})
+	}
+	for _, c := range []struct {
+		size      int
+		minEvents int
+	}{
+		// the snapshot of 5 players leaves room for one event only
+		{minDatagramSize, 1},
+		{splitChildPackageSize, 8},
+	} {
+		scene := room.getPlayerSceneData(players[0])
+		data := fitSceneEvents(scene, c.size)
+		if len(scene.Events) < c.minEvents {
+			t.Errorf("got %d events in the datagram of %d bytes, want %d", len(scene.Events), c.size, c.minEvents)
+		}
+		if got := childHeaderSize + minSealedSize + len(data); got > c.size && len(scene.Events) > 1 {
+			t.Errorf("got datagram of %d bytes, want %d at most", got, c.size)
+		}
+		decoded, err := DecodeServerData(data)
+		if err != nil || len(decoded.Scene.Events) != len(scene.Events) {
+			t.Errorf("got %v decoding the snapshot with %d events", err, len(scene.Events))
+		}
+	}
+}
@@ -10,26 +10,40 @@ type Limit struct {
 	MinY, MaxY int
 }
 
+func (l Limit) Center() Position {
+	return Position{
+		X: (l.MinX + l.MaxX) / 2,
+		Y: (l.MinY + l.MaxY) / 2,
+	}
+}
+
+func (l Limit) Contains(pos Position) bool {
+	return pos.X >= l.MinX && pos.X <= l.MaxX &&
+		pos.Y >= l.MinY && pos.Y <= l.MaxY
+}
+
 type Food struct {
-	pos   Position
-	limit Limit
+	pos Position
+	// golden food speeds up the snake which eats it for a while
+	golden bool
 }
 
 func init() {
 	rand.Seed(time.Now().Unix())
 }
 
-func NewFood(limit Limit) *Food {
-	food := &Food{
-		limit: limit,
+func NewFood(pos Position) *Food {
+	return &Food{
+		pos: pos,
 	}
-	food.UpdatePos()
-	return food
 }
 
-func (f *Food) UpdatePos() {
-	f.pos.X = rand.Intn(f.limit.MaxX-f.limit.MinX+1) + f.limit.MinX
-	f.pos.Y = rand.Intn(f.limit.MaxY-f.limit.MinY+1) + f.limit.MinY
+func (f *Food) GetPos() Position {
+	return f.pos
+}
+
+func (f *Food) IsGolden() bool {
+	return f.golden
 }
 
 func (f *Food) GetTakes() map[Position]struct{} {
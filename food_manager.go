@@ -0,0 +1,102 @@
+package gosnake
+
+import "math/rand"
+
+type FoodManager struct {
+	foods         []*Food
+	limit         Limit
+	strategy      FoodSpawnStrategy
+	goldenPercent int
+}
+
+// NewFoodManager creates the food manager, goldenPercent of the new food
+// items are golden.
+func NewFoodManager(limit Limit, strategy FoodSpawnStrategy, goldenPercent int) *FoodManager {
+	return &FoodManager{
+		limit:         limit,
+		strategy:      strategy,
+		goldenPercent: goldenPercent,
+	}
+}
+
+func (fm *FoodManager) Len() int {
+	return len(fm.foods)
+}
+
+// Update adds or removes food items until there are num of them, new items
+// are only placed on the positions not taken by the occupied takers. When
+// the board is full the missing items are retried on the next update.
+func (fm *FoodManager) Update(num int, occupied Taker, heads []Position) {
+	num = IfInt(num < 1, 1, num)
+	if len(fm.foods) > num {
+		fm.foods = fm.foods[:num]
+		return
+	}
+	if len(fm.foods) == num {
+		return
+	}
+	free := GetFreePositions(fm.limit, occupied, fm)
+	for len(fm.foods) < num {
+		pos, ok := fm.strategy.Pick(free, fm.limit, heads)
+		if !ok {
+			return
+		}
+		food := NewFood(pos)
+		food.golden = rand.Intn(100) < fm.goldenPercent
+		fm.foods = append(fm.foods, food)
+		free = removePosition(free, pos)
+	}
+}
+
+func (fm *FoodManager) GetTakes() map[Position]struct{} {
+	takes := make(map[Position]struct{}, len(fm.foods))
+	for _, food := range fm.foods {
+		takes[food.pos] = struct{}{}
+	}
+	return takes
+}
+
+// GetGoldenPositions returns the positions of the golden food items.
+func (fm *FoodManager) GetGoldenPositions() []Position {
+	var positions []Position
+	for _, food := range fm.foods {
+		if food.golden {
+			positions = append(positions, food.pos)
+		}
+	}
+	return positions
+}
+
+func (fm *FoodManager) IsTaken(pos Position) bool {
+	return fm.getFoodIndex(pos) >= 0
+}
+
+// Eat consumes the food item at pos, it returns nil if there is no
+// food at pos. The item is replaced on the next update.
+func (fm *FoodManager) Eat(pos Position) *Food {
+	i := fm.getFoodIndex(pos)
+	if i < 0 {
+		return nil
+	}
+	food := fm.foods[i]
+	fm.foods = append(fm.foods[:i], fm.foods[i+1:]...)
+	return food
+}
+
+func (fm *FoodManager) getFoodIndex(pos Position) int {
+	for i, food := range fm.foods {
+		if food.IsTaken(pos) {
+			return i
+		}
+	}
+	return -1
+}
+
+func removePosition(positions []Position, pos Position) []Position {
+	for i, p := range positions {
+		if p == pos {
+			return append(positions[:i], positions[i+1:]...)
+		}
+	}
+	return positions
+}
@@ -0,0 +1,84 @@
+package gosnake
+
+import (
+	"math/rand"
+	"sort"
+)
+
+type FoodSpawnStrategy string
+
+const foodSpawnCandidateRatio = 4
+
+const (
+	FoodSpawnRandom        FoodSpawnStrategy = "random"
+	FoodSpawnAwayFromHeads FoodSpawnStrategy = "away_from_heads"
+	FoodSpawnNearCenter    FoodSpawnStrategy = "near_center"
+)
+
+type foodSpawnFunc func(free []Position, limit Limit, heads []Position) Position
+
+var foodSpawnFuncs = map[FoodSpawnStrategy]foodSpawnFunc{
+	FoodSpawnRandom:        spawnFoodRandom,
+	FoodSpawnAwayFromHeads: spawnFoodAwayFromHeads,
+	FoodSpawnNearCenter:    spawnFoodNearCenter,
+}
+
+func (strategy FoodSpawnStrategy) Valid() bool {
+	_, ok := foodSpawnFuncs[strategy]
+	return ok || strategy == ""
+}
+
+// Pick chooses a position from the free positions, ok is false when
+// there is no free position left.
+func (strategy FoodSpawnStrategy) Pick(free []Position, limit Limit, heads []Position) (pos Position, ok bool) {
+	if len(free) == 0 {
+		return
+	}
+	spawn, exists := foodSpawnFuncs[strategy]
+	if !exists {
+		spawn = spawnFoodRandom
+	}
+	return spawn(free, limit, heads), true
+}
+
+func spawnFoodRandom(free []Position, limit Limit, heads []Position) Position {
+	return free[rand.Intn(len(free))]
+}
+
+// spawnFoodAwayFromHeads picks randomly among the free positions which are
+// the farthest from every snake head.
+func spawnFoodAwayFromHeads(free []Position, limit Limit, heads []Position) Position {
+	if len(heads) == 0 {
+		return spawnFoodRandom(free, limit, heads)
+	}
+	return pickFromBest(free, func(pos Position) int {
+		nearest := -1
+		for _, head := range heads {
+			d := pos.Distance(head)
+			if nearest < 0 || d < nearest {
+				nearest = d
+			}
+		}
+		return nearest
+	})
+}
+
+// spawnFoodNearCenter picks randomly among the free positions which are
+// the nearest to the center of the limit.
+func spawnFoodNearCenter(free []Position, limit Limit, heads []Position) Position {
+	center := limit.Center()
+	return pickFromBest(free, func(pos Position) int {
+		return -pos.Distance(center)
+	})
+}
+
+// pickFromBest picks randomly among the top scored part of positions.
+func pickFromBest(positions []Position, score func(Position) int) Position {
+	candidates := make([]Position, len(positions))
+	copy(candidates, positions)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return score(candidates[i]) > score(candidates[j])
+	})
+	n := len(candidates)/foodSpawnCandidateRatio + 1
+	return candidates[rand.Intn(n)]
+}
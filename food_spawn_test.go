@@ -0,0 +1,41 @@
+package gosnake
+
+import (
+	"testing"
+)
+
+func TestFoodManagerSpawnsOnFreePositions(t *testing.T) {
+	limit := Limit{MinX: 1, MaxX: 4, MinY: 1, MaxY: 4}
+	occupied := NewCompressLayer(6, 6)
+	occupied.AddPositions(map[Position]struct{}{
+		{X: 1, Y: 1}: {}, {X: 2, Y: 1}: {}, {X: 3, Y: 1}: {}, {X: 4, Y: 1}: {},
+		{X: 1, Y: 2}: {}, {X: 2, Y: 2}: {}, {X: 3, Y: 2}: {}, {X: 4, Y: 2}: {},
+		{X: 1, Y: 3}: {}, {X: 2, Y: 3}: {},
+	})
+	strategies := []FoodSpawnStrategy{
+		FoodSpawnRandom, FoodSpawnAwayFromHeads, FoodSpawnNearCenter,
+	}
+	for _, strategy := range strategies {
+		fm := NewFoodManager(limit, strategy, 0)
+		fm.Update(10, occupied, []Position{{X: 1, Y: 1}})
+		if fm.Len() != 6 {
+			t.Errorf("%s: food num is %d, want 6", strategy, fm.Len())
+		}
+		for pos := range fm.GetTakes() {
+			if occupied.IsTaken(pos) {
+				t.Errorf("%s: food spawned on taken pos %v", strategy, pos)
+			}
+		}
+	}
+}
+
+func TestFoodManagerFullBoard(t *testing.T) {
+	limit := Limit{MinX: 0, MaxX: 1, MinY: 0, MaxY: 0}
+	occupied := NewCompressLayer(2, 1)
+	occupied.AddPositions(map[Position]struct{}{{X: 0, Y: 0}: {}, {X: 1, Y: 0}: {}})
+	fm := NewFoodManager(limit, FoodSpawnRandom, 0)
+	fm.Update(1, occupied, nil)
+	if fm.Len() != 0 {
+		t.Errorf("food spawned on a full board")
+	}
+}
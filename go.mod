@@ -1,5 +1,10 @@
 module gosnake
 
-go 1.15
+go 1.21
 
 require golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4
+
+require (
+	golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1 // indirect
+)
@@ -6,6 +6,7 @@ const (
 	CodeQuit   Code = 'q'
 	CodePause  Code = 'p'
 	CodeReplay Code = 'r'
+	CodeBoost  Code = ' '
 	CodeUp     Code = 'w'
 	CodeLeft   Code = 'a'
 	CodeDown   Code = 's'
@@ -14,4 +15,11 @@ const (
 	CodeLeft2  Code = 'j'
 	CodeDown2  Code = 'k'
 	CodeRight2 Code = 'l'
+
+	CodeChat      Code = 't'
+	CodeEnter     Code = '\r'
+	CodeEscape    Code = 27
+	CodeBackspace Code = 127
+	CodeEmote1    Code = '1'
+	CodeEmote9    Code = '9'
 )
@@ -0,0 +1,87 @@
+package gosnake
+
+import "time"
+
+const lossWindow = 100
+
+// LatencyStats tracks the smoothed round trip time and its jitter in the
+// way of RFC 3550.
+type LatencyStats struct {
+	rtt     time.Duration
+	jitter  time.Duration
+	last    time.Duration
+	samples int
+}
+
+func (ls *LatencyStats) Update(rtt time.Duration) {
+	if rtt < 0 {
+		return
+	}
+	if ls.samples == 0 {
+		ls.rtt = rtt
+	} else {
+		d := rtt - ls.last
+		if d < 0 {
+			d = -d
+		}
+		ls.jitter += (d - ls.jitter) / 16
+		ls.rtt += (rtt - ls.rtt) / 8
+	}
+	ls.last = rtt
+	ls.samples += 1
+}
+
+func (ls *LatencyStats) GetRTT() time.Duration {
+	return ls.rtt
+}
+
+func (ls *LatencyStats) GetJitter() time.Duration {
+	return ls.jitter
+}
+
+// LossEstimator estimates the packet loss from the gaps of the sequence
+// numbers, the loss is updated every lossWindow sequence numbers. The
+// window restarts when the sequence jumps back over a window, since the
+// server numbers the snapshots of a rejoined player from the start.
+type LossEstimator struct {
+	first    uint64
+	last     uint64
+	received uint64
+	loss     float64
+}
+
+func (le *LossEstimator) Receive(seq uint64) {
+	if le.first == 0 {
+		le.first, le.last = seq, seq
+	}
+	if seq < le.first {
+		if le.first-seq <= lossWindow {
+			// a late packet of the last window
+			return
+		}
+		le.Reset()
+		le.first, le.last = seq, seq
+	}
+	if seq > le.last {
+		le.last = seq
+	}
+	le.received += 1
+	expected := le.last - le.first + 1
+	if expected < lossWindow {
+		return
+	}
+	le.loss = 0
+	if le.received < expected {
+		le.loss = 1 - float64(le.received)/float64(expected)
+	}
+	le.first, le.last, le.received = le.last+1, le.last+1, 0
+}
+
+// Reset restarts the window, the last loss is kept until the window ends.
+func (le *LossEstimator) Reset() {
+	le.first, le.last, le.received = 0, 0, 0
+}
+
+func (le *LossEstimator) GetLoss() float64 {
+	return le.loss
+}
@@ -0,0 +1,41 @@
+package gosnake
+
+import "testing"
+
+func TestLossEstimator(t *testing.T) {
+	seqs := func(from, to uint64, skip uint64) (s []uint64) {
+		for seq := from; seq <= to; seq++ {
+			if skip == 0 || (seq-from)%skip != 1 {
+				s = append(s, seq)
+			}
+		}
+		return
+	}
+	for _, c := range []struct {
+		name  string
+		reset bool
+		seqs  [][]uint64
+		want  float64
+	}{
+		{"no loss", false, [][]uint64{seqs(1, 100, 0)}, 0},
+		{"every tenth lost", false, [][]uint64{seqs(1, 100, 10)}, 0.1},
+		{"window not ended", false, [][]uint64{seqs(1, 50, 2)}, 0},
+		{"late packet", false, [][]uint64{seqs(1, 100, 0), {99}, seqs(101, 200, 0)}, 0},
+		// the server numbers the snapshots from the start after a rejoin
+		{"sequence restarted", false, [][]uint64{seqs(1000, 1099, 0), seqs(1, 100, 4)}, 0.25},
+		{"reset on the join", true, [][]uint64{seqs(1, 150, 0), seqs(1, 100, 5)}, 0.2},
+	} {
+		var le LossEstimator
+		for i, s := range c.seqs {
+			if c.reset && i > 0 {
+				le.Reset()
+			}
+			for _, seq := range s {
+				le.Receive(seq)
+			}
+		}
+		if got := le.GetLoss(); got < c.want-1e-9 || got > c.want+1e-9 {
+			t.Errorf("%s: got loss %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package gosnake
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+const (
+	inviteCodeLength   = 6
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	listRoomsTimeout   = 3 * time.Second
+	// createdRoomIdle is how long a room created by a player stays open
+	// without players, so that the rooms are not piled up to the max.
+	createdRoomIdle = time.Minute
+	// maxCreatedRooms caps the rooms created by the players, the rest of
+	// max_rooms is left to the admin.
+	maxCreatedRooms = 10
+)
+
+// RoomInfo describes a room in the lobby listing, the player number of a
+// private room is not revealed.
+type RoomInfo struct {
+	ID         int
+	Players    int
+	PlayerSize int
+	Private    bool
+}
+
+// RoomCreatedData replies the creation of a private room.
+type RoomCreatedData struct {
+	RoomID     int
+	InviteCode string
+}
+
+func (info *RoomInfo) encode(w *wireWriter) {
+	w.Int(int64(info.ID))
+	w.Uint(uint64(info.Players))
+	w.Uint(uint64(info.PlayerSize))
+	w.Bool(info.Private)
+}
+
+func (info *RoomInfo) decode(r *wireReader) {
+	info.ID = int(r.Int())
+	info.Players = int(r.Uint())
+	info.PlayerSize = int(r.Uint())
+	info.Private = r.Bool()
+}
+
+func NewInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+func (s *Server) handleListRooms(sender *net.UDPAddr) {
+	s.mu.RLock()
+	rooms := make([]*RoomInfo, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		if !room.IsRetired() {
+			rooms = append(rooms, room.GetInfo())
+		}
+	}
+	s.mu.RUnlock()
+	s.sendData((&ServerData{Rooms: rooms}).Encode(), sender, minDatagramSize)
+}
+
+// handleCreateRoom creates a private room with the password of the sender
+// and a new invite code.
+func (s *Server) handleCreateRoom(cliData *ClientData, sender *net.UDPAddr) {
+	if s.IsDraining() {
+		s.sendError(sender, minDatagramSize, shutdownReason)
+		return
+	}
+	code, err := NewInviteCode()
+	if err != nil {
+		s.sendError(sender, minDatagramSize, "failed to create room")
+		return
+	}
+	s.mu.Lock()
+	if s.countActiveRooms() >= s.options.MaxRooms || s.countCreatedRooms() >= maxCreatedRooms {
+		s.mu.Unlock()
+		s.sendError(sender, minDatagramSize, "too many rooms")
+		return
+	}
+	options := s.options.RoomOptions.clone()
+	options.Password = cliData.Password
+	options.InviteCode = code
+	room := s.startRoom(options, true)
+	s.mu.Unlock()
+	logger(LogServer).Info("room created", "room", room.GetID(), "player", sender.String())
+	data := &ServerData{
+		RoomCreated: &RoomCreatedData{RoomID: room.GetID(), InviteCode: code},
+	}
+	s.sendData(data.Encode(), sender, minDatagramSize)
+}
+
+// countActiveRooms returns the number of the rooms which are not retired,
+// the caller holds the lock.
+func (s *Server) countActiveRooms() (n int) {
+	for _, room := range s.rooms {
+		if !room.IsRetired() {
+			n++
+		}
+	}
+	return
+}
+
+// countCreatedRooms returns the number of the running rooms created by
+// the players, the caller holds the lock.
+func (s *Server) countCreatedRooms() (n int) {
+	for _, room := range s.rooms {
+		if room.created {
+			n++
+		}
+	}
+	return
+}
+
+func (s *Server) findInviteCode(code string) (roomID int, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, room := range s.rooms {
+		inviteCode := room.GetInviteCode()
+		if inviteCode != "" && inviteCode == code {
+			return room.GetID(), true
+		}
+	}
+	return
+}
+
+// ListRooms requests the lobby listing of the server.
+func ListRooms(ctx context.Context, serverAddr string) ([]*RoomInfo, error) {
+	network := NewNetWork(splitChildPackageSize, splitChildPackageNum)
+	if err := network.Start("", serverAddr); err != nil {
+		return nil, err
+	}
+	defer network.Stop()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&ClientData{CMD: CMDListRooms}); err != nil {
+		return nil, err
+	}
+	network.Send <- buf.Bytes()
+	ctx, cancel := context.WithTimeout(ctx, listRoomsTimeout)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("the server did not respond")
+		case data := <-network.Recv:
+			serverData, err := DecodeServerData(data)
+			if err == nil && serverData.Rooms != nil {
+				return serverData.Rooms, nil
+			}
+		}
+	}
+}
+
+// PrintRooms prints the lobby listing of the server.
+func PrintRooms(ctx context.Context, serverAddr string) error {
+	rooms, err := ListRooms(ctx, serverAddr)
+	if err != nil {
+		return err
+	}
+	fmt.Println(" room   players   access")
+	for _, info := range rooms {
+		if info.Private {
+			fmt.Printf(" %-4d   %7s   private\n", info.ID, "-")
+			continue
+		}
+		fmt.Printf(" %-4d   %3d/%-3d   public\n", info.ID, info.Players, info.PlayerSize)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package gosnake
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSecret(t *testing.T) {
+	for _, c := range []struct {
+		name                 string
+		options              RoomOptions
+		password, inviteCode string
+		want                 bool
+	}{
+		{"public", RoomOptions{}, "", "", true},
+		{"public with secret", RoomOptions{}, "x", "y", true},
+		{"password", RoomOptions{Password: "pw"}, "pw", "", true},
+		{"wrong password", RoomOptions{Password: "pw"}, "px", "", false},
+		{"empty password", RoomOptions{Password: "pw"}, "", "", false},
+		{"invite code", RoomOptions{InviteCode: "ABC234"}, "", "ABC234", true},
+		{"wrong invite code", RoomOptions{InviteCode: "ABC234"}, "", "ABC235", false},
+		{"invite code for password", RoomOptions{Password: "pw"}, "", "pw", false},
+		{"either", RoomOptions{Password: "pw", InviteCode: "ABC234"}, "", "ABC234", true},
+	} {
+		if got := c.options.CheckSecret(c.password, c.inviteCode); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNewInviteCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := NewInviteCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != inviteCodeLength {
+			t.Fatalf("got invite code %q of length %d", code, len(code))
+		}
+		for _, r := range code {
+			if !strings.ContainsRune(inviteCodeAlphabet, r) {
+				t.Fatalf("got invite code %q out of the alphabet", code)
+			}
+		}
+		if seen[code] {
+			t.Fatalf("got invite code %q twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestFindRoom(t *testing.T) {
+	server := NewServer(DefaultServerOptions)
+	noop := func([]byte, *net.UDPAddr, int) {}
+	private := *DefaultServerOptions.RoomOptions
+	private.InviteCode = "ABC234"
+	server.rooms = map[int]*Room{
+		0: NewRoom(0, DefaultServerOptions.RoomOptions, noop),
+		1: NewRoom(1, &private, noop),
+	}
+
+	for _, c := range []struct {
+		code string
+		id   int
+		ok   bool
+	}{
+		{"ABC234", 1, true},
+		{"ABC235", 0, false},
+		{"", 0, false},
+	} {
+		if id, ok := server.findInviteCode(c.code); id != c.id || ok != c.ok {
+			t.Errorf("findInviteCode(%q) = %d, %v, want %d, %v", c.code, id, ok, c.id, c.ok)
+		}
+	}
+	for _, c := range []struct {
+		id int
+		ok bool
+	}{
+		{-1, false}, {0, true}, {1, true}, {2, false},
+	} {
+		if room := server.getRoom(c.id); (room != nil) != c.ok || (room != nil && room.GetID() != c.id) {
+			t.Errorf("getRoom(%d) = %v, want found %v", c.id, room, c.ok)
+		}
+	}
+}
+
+func TestRoomIdleRetire(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		idleRetire time.Duration
+		emptyFor   time.Duration
+		players    bool
+		want       bool
+	}{
+		{"configured", 0, time.Hour, false, false},
+		{"empty for a while", createdRoomIdle, time.Second, false, false},
+		{"empty for the idle", createdRoomIdle, createdRoomIdle, false, true},
+		{"not empty", createdRoomIdle, createdRoomIdle, true, false},
+	} {
+		room := NewRoom(0, DefaultServerOptions.RoomOptions, func([]byte, *net.UDPAddr, int) {})
+		room.Init()
+		room.idleRetire = c.idleRetire
+		if room.checkIdle() {
+			t.Errorf("%s: the room stops once it is empty", c.name)
+		}
+		if c.players {
+			addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+			room.handleData(&RoomData{Sender: addr, ClientData: &ClientData{CMD: CMDJoin}})
+		}
+		if !room.emptySince.IsZero() {
+			room.emptySince = room.emptySince.Add(-c.emptyFor)
+		}
+		if got := room.checkIdle(); got != c.want || room.IsRetired() != c.want {
+			t.Errorf("%s: got stop %v and retired %v, want %v", c.name, got, room.IsRetired(), c.want)
+		}
+	}
+}
+
+func TestCreateRoom(t *testing.T) {
+	options := DefaultServerOptions.clone()
+	options.Addr = "127.0.0.1:0"
+	options.RoomSize = 1
+	server := NewServer(options)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx)
+	for len(server.getRooms()) < options.RoomSize {
+		time.Sleep(time.Millisecond)
+	}
+
+	sender := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	for i := 0; i <= maxCreatedRooms; i++ {
+		server.handleCreateRoom(&ClientData{CMD: CMDCreateRoom}, sender)
+	}
+	rooms := server.getRooms()
+	if len(rooms) != options.RoomSize+maxCreatedRooms {
+		t.Fatalf("got %d rooms, want %d", len(rooms), options.RoomSize+maxCreatedRooms)
+	}
+
+	// the stopped room is forgotten and its id is not reused
+	stopped := rooms[1]
+	stopped.Retire()
+	<-stopped.Done()
+	deadline := time.Now().Add(time.Second)
+	for server.getRoom(stopped.GetID()) != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if server.getRoom(stopped.GetID()) != nil {
+		t.Fatal("stopped room is not removed")
+	}
+	server.handleCreateRoom(&ClientData{CMD: CMDCreateRoom}, sender)
+	rooms = server.getRooms()
+	if last := rooms[len(rooms)-1]; len(rooms) != options.RoomSize+maxCreatedRooms ||
+		last.GetID() != options.RoomSize+maxCreatedRooms {
+		t.Errorf("got %d rooms and last id %d after a room stopped", len(rooms), last.GetID())
+	}
+}
@@ -0,0 +1,151 @@
+package gosnake
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// The subsystems log with their own levels.
+const (
+	LogNetwork = "network"
+	LogRoom    = "room"
+	LogGame    = "game"
+	LogServer  = "server"
+	LogClient  = "client"
+)
+
+var logSubsystems = []string{LogNetwork, LogRoom, LogGame, LogServer, LogClient}
+
+type LogOptions struct {
+	// Level is the level of the subsystems missing in Levels, one of
+	// debug, info, warn and error.
+	Level string
+	// Levels sets the levels of the subsystems, like network=debug,room=warn.
+	Levels string
+	// Format is text or json.
+	Format string
+	// File is the file the logs are appended to, the logs are written to
+	// stderr if it is empty.
+	File string
+}
+
+var DefaultLogOptions = &LogOptions{
+	Level:  "info",
+	Format: "text",
+}
+
+// loggers maps the subsystems to their loggers, it is replaced as a whole
+// by SetupLogging.
+var loggers atomic.Value
+
+func init() {
+	l, _ := newLoggers(DefaultLogOptions, os.Stderr)
+	loggers.Store(l)
+}
+
+// logger returns the logger of the subsystem.
+func logger(subsystem string) *slog.Logger {
+	return loggers.Load().(map[string]*slog.Logger)[subsystem]
+}
+
+// Logger returns the logger of the subsystem for the commands.
+func Logger(subsystem string) *slog.Logger {
+	return logger(subsystem)
+}
+
+// SetupLogging replaces the loggers of the subsystems with the options,
+// the returned closer closes the log file if any.
+func SetupLogging(options *LogOptions) (io.Closer, error) {
+	var out io.WriteCloser = nopCloser{os.Stderr}
+	if options.File != "" {
+		f, err := os.OpenFile(options.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		out = f
+	}
+	l, err := newLoggers(options, out)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	loggers.Store(l)
+	return out, nil
+}
+
+func newLoggers(options *LogOptions, out io.Writer) (map[string]*slog.Logger, error) {
+	levels, err := parseLogLevels(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Format != "text" && options.Format != "json" {
+		return nil, fmt.Errorf("log format %q is unknown", options.Format)
+	}
+	// the handlers of the subsystems share the writer
+	out = &lockedWriter{w: out}
+	l := make(map[string]*slog.Logger, len(logSubsystems))
+	for _, subsystem := range logSubsystems {
+		handlerOptions := &slog.HandlerOptions{Level: levels[subsystem]}
+		var handler slog.Handler
+		if options.Format == "json" {
+			handler = slog.NewJSONHandler(out, handlerOptions)
+		} else {
+			handler = slog.NewTextHandler(out, handlerOptions)
+		}
+		l[subsystem] = slog.New(handler).With("subsystem", subsystem)
+	}
+	return l, nil
+}
+
+func parseLogLevels(options *LogOptions) (map[string]slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(options.Level)); err != nil {
+		return nil, fmt.Errorf("log level %q is unknown", options.Level)
+	}
+	levels := make(map[string]slog.Level, len(logSubsystems))
+	for _, subsystem := range logSubsystems {
+		levels[subsystem] = level
+	}
+	if options.Levels == "" {
+		return levels, nil
+	}
+	for _, item := range strings.Split(options.Levels, ",") {
+		subsystem, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		if _, ok := levels[subsystem]; !ok {
+			return nil, fmt.Errorf(
+				"log subsystem %q is unknown, want one of %s",
+				subsystem, strings.Join(logSubsystems, ", "),
+			)
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return nil, fmt.Errorf("log level %q of %s is unknown", value, subsystem)
+		}
+		levels[subsystem] = level
+	}
+	return levels, nil
+}
+
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,44 @@
+package gosnake
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggers(t *testing.T) {
+	var b strings.Builder
+	l, err := newLoggers(&LogOptions{
+		Level: "info", Levels: "network=debug, room=error", Format: "json",
+	}, &b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	for subsystem, want := range map[string]slog.Level{
+		LogNetwork: slog.LevelDebug,
+		LogRoom:    slog.LevelError,
+		LogGame:    slog.LevelInfo,
+	} {
+		handler := l[subsystem].Handler()
+		if !handler.Enabled(ctx, want) || handler.Enabled(ctx, want-1) {
+			t.Errorf("%s: level is not %s", subsystem, want)
+		}
+	}
+	l[LogGame].Info("snake died", "cause", DeathWall)
+	if !strings.Contains(b.String(), `"subsystem":"game","cause":"wall"`) {
+		t.Errorf("got log %s", b.String())
+	}
+
+	for _, options := range []*LogOptions{
+		{Level: "verbose", Format: "text"},
+		{Level: "info", Format: "xml"},
+		{Level: "info", Format: "text", Levels: "physics=debug"},
+		{Level: "info", Format: "text", Levels: "room=loud"},
+	} {
+		if _, err := newLoggers(options, &b); err == nil {
+			t.Errorf("%+v: got no error", options)
+		}
+	}
+}
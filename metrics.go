@@ -0,0 +1,170 @@
+package gosnake
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RoomStats are the counters of a room exported as the metrics.
+type RoomStats struct {
+	ID               int                   `json:"id"`
+	Players          int                   `json:"players"`
+	Retired          bool                  `json:"retired"`
+	Ticks            uint64                `json:"ticks"`
+	TickTime         time.Duration         `json:"tick_time_ns"`
+	FoodEaten        uint64                `json:"food_eaten"`
+	Kills            uint64                `json:"kills"`
+	Deaths           map[DeathCause]uint64 `json:"deaths"`
+	SnapshotsDropped uint64                `json:"snapshots_dropped"`
+	LimitedCMDs      uint64                `json:"limited_cmds"`
+}
+
+// GetStats returns the counters of the room, it is safe to call it from
+// other goroutines.
+func (room *Room) GetStats() *RoomStats {
+	stats := &RoomStats{
+		ID:               room.id,
+		Players:          int(atomic.LoadInt32(&room.playerNum)),
+		Retired:          room.IsRetired(),
+		Ticks:            atomic.LoadUint64(&room.counters.ticks),
+		TickTime:         time.Duration(atomic.LoadUint64(&room.counters.tickNanos)),
+		FoodEaten:        atomic.LoadUint64(&room.counters.foodEaten),
+		Kills:            atomic.LoadUint64(&room.counters.kills),
+		Deaths:           make(map[DeathCause]uint64, len(deathCauses)),
+		SnapshotsDropped: room.writer.GetDropped(),
+		LimitedCMDs:      room.GetLimitedCMDs(),
+	}
+	for cause, n := range room.counters.deaths {
+		stats.Deaths[cause] = atomic.LoadUint64(n)
+	}
+	return stats
+}
+
+// WriteMetrics writes the metrics of the server in the Prometheus text
+// format.
+func (s *Server) WriteMetrics(w io.Writer) {
+	rooms := s.getRooms()
+	stats := make([]*RoomStats, 0, len(rooms))
+	for _, room := range rooms {
+		stats = append(stats, room.GetStats())
+	}
+	mw := &metricWriter{w: w}
+
+	activeRooms, players := 0, 0
+	for _, rs := range stats {
+		if !rs.Retired {
+			activeRooms++
+		}
+		players += rs.Players
+	}
+	mw.header("gosnake_rooms", "gauge", "The rooms open to the players.")
+	mw.sample("gosnake_rooms", nil, activeRooms)
+	mw.header("gosnake_players", "gauge", "The players in the rooms.")
+	mw.sample("gosnake_players", nil, players)
+
+	mw.header("gosnake_room_players", "gauge", "The players in the room.")
+	for _, rs := range stats {
+		mw.sample("gosnake_room_players", roomLabel(rs), rs.Players)
+	}
+	mw.header("gosnake_room_ticks_total", "counter", "The ticks run by the room.")
+	for _, rs := range stats {
+		mw.sample("gosnake_room_ticks_total", roomLabel(rs), rs.Ticks)
+	}
+	mw.header("gosnake_room_tick_seconds_total", "counter", "The time spent in the ticks of the room.")
+	for _, rs := range stats {
+		mw.sample("gosnake_room_tick_seconds_total", roomLabel(rs), rs.TickTime.Seconds())
+	}
+	mw.header("gosnake_room_snapshots_dropped_total", "counter", "The snapshots dropped since they exceed the bandwidth of the client.")
+	for _, rs := range stats {
+		mw.sample("gosnake_room_snapshots_dropped_total", roomLabel(rs), rs.SnapshotsDropped)
+	}
+
+	var foodEaten, kills uint64
+	deaths := make(map[DeathCause]uint64, len(deathCauses))
+	for _, rs := range stats {
+		foodEaten += rs.FoodEaten
+		kills += rs.Kills
+		for cause, n := range rs.Deaths {
+			deaths[cause] += n
+		}
+	}
+	mw.header("gosnake_food_eaten_total", "counter", "The food eaten by the snakes.")
+	mw.sample("gosnake_food_eaten_total", nil, foodEaten)
+	mw.header("gosnake_deaths_total", "counter", "The deaths of the snakes by cause.")
+	for _, cause := range deathCauses {
+		mw.sample("gosnake_deaths_total", []string{"cause", string(cause)}, deaths[cause])
+	}
+	mw.header("gosnake_kills_total", "counter", "The deaths of the snakes caused by other snakes.")
+	mw.sample("gosnake_kills_total", nil, kills)
+
+	mw.header("gosnake_received_packets_total", "counter", "The packets received by the server.")
+	mw.sample("gosnake_received_packets_total", nil, atomic.LoadUint64(&s.packetsIn))
+	mw.header("gosnake_received_bytes_total", "counter", "The bytes received by the server.")
+	mw.sample("gosnake_received_bytes_total", nil, atomic.LoadUint64(&s.bytesIn))
+
+	send := s.splitDataSender.GetStats()
+	mw.header("gosnake_sent_packets_total", "counter", "The packets sent by the server.")
+	mw.sample("gosnake_sent_packets_total", nil, send.Children)
+	mw.header("gosnake_sent_bytes_total", "counter", "The bytes sent by the server.")
+	mw.sample("gosnake_sent_bytes_total", nil, send.Bytes)
+	mw.header("gosnake_send_errors_total", "counter", "The packets failed to send.")
+	mw.sample("gosnake_send_errors_total", nil, send.Errors)
+	mw.header("gosnake_frame_fragments", "histogram", "The packets per frame sent by the server.")
+	var cumulative uint64
+	for i, bound := range fragmentBuckets {
+		cumulative += send.Fragments[i]
+		mw.sample("gosnake_frame_fragments_bucket", []string{"le", strconv.Itoa(int(bound))}, cumulative)
+	}
+	cumulative += send.Fragments[len(fragmentBuckets)]
+	mw.sample("gosnake_frame_fragments_bucket", []string{"le", "+Inf"}, cumulative)
+	mw.sample("gosnake_frame_fragments_sum", nil, send.Children+send.Errors)
+	mw.sample("gosnake_frame_fragments_count", nil, send.Frames)
+
+	abuse := s.GetAbuseStats()
+	mw.header("gosnake_decode_errors_total", "counter", "The packets could not be opened or decoded.")
+	mw.sample("gosnake_decode_errors_total", nil, abuse.Malformed)
+	mw.header("gosnake_dropped_packets_total", "counter", "The packets dropped since their room is busy.")
+	mw.sample("gosnake_dropped_packets_total", nil, abuse.Dropped)
+	mw.header("gosnake_rate_limited_total", "counter", "The packets refused by the rate limits.")
+	mw.sample("gosnake_rate_limited_total", []string{"limit", "address"}, abuse.RateLimited)
+	mw.sample("gosnake_rate_limited_total", []string{"limit", "join"}, abuse.JoinLimited)
+	mw.sample("gosnake_rate_limited_total", []string{"limit", "player"}, abuse.PlayerLimited)
+	mw.header("gosnake_bans_total", "counter", "The addresses banned.")
+	mw.sample("gosnake_bans_total", nil, abuse.Banned)
+	mw.header("gosnake_banned_packets_total", "counter", "The packets of the banned addresses.")
+	mw.sample("gosnake_banned_packets_total", nil, abuse.BannedPackets)
+}
+
+func roomLabel(rs *RoomStats) []string {
+	return []string{"room", strconv.Itoa(rs.ID)}
+}
+
+// metricWriter writes the lines of the Prometheus text format.
+type metricWriter struct {
+	w io.Writer
+}
+
+func (mw *metricWriter) header(name, typ, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample, the labels are pairs of names and values.
+func (mw *metricWriter) sample(name string, labels []string, value interface{}) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=%q", labels[i], labels[i+1])
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(mw.w, "%s %v\n", b.String(), value)
+}
@@ -0,0 +1,37 @@
+package gosnake
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	server := NewServer(DefaultServerOptions)
+	room := NewRoom(0, DefaultServerOptions.RoomOptions, nil)
+	room.counters.foodEaten = 3
+	*room.counters.deaths[DeathHeadOn] = 2
+	server.rooms[0] = room
+	for _, n := range []uint32{1, 1, 3, 9} {
+		server.splitDataSender.countFrame(n)
+	}
+
+	var b strings.Builder
+	server.WriteMetrics(&b)
+	metrics := b.String()
+	for _, line := range []string{
+		"# TYPE gosnake_deaths_total counter",
+		`gosnake_room_players{room="0"} 0`,
+		"gosnake_food_eaten_total 3",
+		`gosnake_deaths_total{cause="head_on"} 2`,
+		`gosnake_deaths_total{cause="wall"} 0`,
+		`gosnake_frame_fragments_bucket{le="1"} 2`,
+		`gosnake_frame_fragments_bucket{le="4"} 3`,
+		`gosnake_frame_fragments_bucket{le="8"} 3`,
+		`gosnake_frame_fragments_bucket{le="+Inf"} 4`,
+		"gosnake_frame_fragments_count 4",
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("metrics miss %q", line)
+		}
+	}
+}
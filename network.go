@@ -28,6 +28,11 @@ func (nw *Network) GetLocalAddr() string {
 	return nw.conn.LocalAddr().String()
 }
 
+// GetRecvStats returns the counters of the reassembly of received data.
+func (nw *Network) GetRecvStats() SplitStats {
+	return nw.splitDataReciever.GetStats()
+}
+
 func (nw *Network) Start(localAddr, remoteAddr string) error {
 	var (
 		err          error
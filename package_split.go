@@ -3,9 +3,9 @@ package gosnake
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"net"
 	"sync/atomic"
+	"time"
 )
 
 const childHeaderSize = 16
@@ -33,31 +33,87 @@ func decodeChildPackage(data []byte) (*childPackageHeader, []byte) {
 	return header, data[16:]
 }
 
+// fragmentBuckets are the upper bounds of the histogram of the children
+// per frame.
+var fragmentBuckets = []uint32{1, 2, 4, 8}
+
+// SendStats counts the frames and their children sent, Fragments counts
+// the frames by the upper bounds of fragmentBuckets, the last one counts
+// the frames over all the bounds.
+type SendStats struct {
+	Frames    uint64   `json:"frames"`
+	Children  uint64   `json:"packets"`
+	Bytes     uint64   `json:"bytes"`
+	Errors    uint64   `json:"errors"`
+	Fragments []uint64 `json:"fragments"`
+}
+
 type SplitDataSender struct {
-	childDataSize uint32
-	serialNumber  uint64
+	childSize    uint32
+	pace         time.Duration
+	serialNumber uint64
+	stats        SendStats
 }
 
-func NewSplitDataSender(childSize uint32) *SplitDataSender {
-	size := childSize - childHeaderSize
-	if size <= 0 {
+// NewSplitDataSender creates a sender which splits the data into children
+// of childSize at most, and waits pace between the children of the same
+// data to avoid bursts.
+func NewSplitDataSender(childSize uint32, pace time.Duration) *SplitDataSender {
+	if childSize <= childHeaderSize {
 		panic("child package size is too small")
 	}
 	return &SplitDataSender{
-		childDataSize: size,
+		childSize: childSize,
+		pace:      pace,
+		stats: SendStats{
+			Fragments: make([]uint64, len(fragmentBuckets)+1),
+		},
+	}
+}
+
+func (sds *SplitDataSender) GetStats() SendStats {
+	stats := SendStats{
+		Frames:    atomic.LoadUint64(&sds.stats.Frames),
+		Children:  atomic.LoadUint64(&sds.stats.Children),
+		Bytes:     atomic.LoadUint64(&sds.stats.Bytes),
+		Errors:    atomic.LoadUint64(&sds.stats.Errors),
+		Fragments: make([]uint64, len(sds.stats.Fragments)),
+	}
+	for i := range stats.Fragments {
+		stats.Fragments[i] = atomic.LoadUint64(&sds.stats.Fragments[i])
 	}
+	return stats
 }
 
-func (sds *SplitDataSender) SendDataWithUDP(data []byte, conn *net.UDPConn, addr *net.UDPAddr) {
+func (sds *SplitDataSender) countFrame(childrenNum uint32) {
+	atomic.AddUint64(&sds.stats.Frames, 1)
+	i := 0
+	for i < len(fragmentBuckets) && childrenNum > fragmentBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&sds.stats.Fragments[i], 1)
+}
+
+// SendDataWithUDP sends the data split into children of childSize, the
+// default child size is used if childSize is zero or too large.
+func (sds *SplitDataSender) SendDataWithUDP(data []byte, conn *net.UDPConn, addr *net.UDPAddr, childSize uint32) {
+	if childSize <= childHeaderSize || childSize > sds.childSize {
+		childSize = sds.childSize
+	}
+	childDataSize := childSize - childHeaderSize
 	datal := uint32(len(data))
-	childrenNum := datal / sds.childDataSize
-	if datal%sds.childDataSize != 0 {
+	childrenNum := datal / childDataSize
+	if datal%childDataSize != 0 {
 		childrenNum += 1
 	}
 	serialNum := atomic.AddUint64(&sds.serialNumber, 1)
+	sds.countFrame(childrenNum)
 	for i := uint32(0); i < childrenNum; i++ {
-		s := i * sds.childDataSize
-		e := s + sds.childDataSize
+		if i > 0 && sds.pace > 0 {
+			time.Sleep(sds.pace)
+		}
+		s := i * childDataSize
+		e := s + childDataSize
 		if e > datal {
 			e = datal
 		}
@@ -67,28 +123,62 @@ func (sds *SplitDataSender) SendDataWithUDP(data []byte, conn *net.UDPConn, addr
 			total:        childrenNum,
 		}
 		childData := encodeChildPackage(header, data[s:e])
-		fmt.Printf("[S] %s %d\n", addr, len(childData))
-		conn.WriteToUDP(childData, addr)
+		logger(LogNetwork).Debug("fragment sent", "addr", addr, "size", len(childData))
+		n, err := conn.WriteToUDP(childData, addr)
+		if err != nil {
+			atomic.AddUint64(&sds.stats.Errors, 1)
+			continue
+		}
+		atomic.AddUint64(&sds.stats.Children, 1)
+		atomic.AddUint64(&sds.stats.Bytes, uint64(n))
 	}
 }
 
+const (
+	maxInFlightFrames = 4
+	frameExpiry       = time.Second
+	// serialRestartGap is how far the serial number jumps back when the
+	// sender restarts, the reordered children never go back so far.
+	serialRestartGap = 1 << 16
+)
+
+type splitFrame struct {
+	children  [][]byte
+	received  uint32
+	childLen  int
+	createdAt time.Time
+}
+
+// SplitStats counts the results of the reassembly, Dropped counts the
+// invalid, duplicated and outdated children, Incomplete counts the frames
+// expired or evicted before all of their children arrived.
+type SplitStats struct {
+	Completed  uint64
+	Dropped    uint64
+	Incomplete uint64
+}
+
+// SplitDataReciever reassembles the frames from their children, it keeps
+// a small window of frames in flight so the children of different frames
+// can arrive in any order.
 type SplitDataReciever struct {
-	childSize        uint32
-	buffer           [][]byte
-	serialNumber     uint64
-	totalChildren    uint32
-	receivedChildren uint32
+	childSize   uint32
+	maxChildren uint32
+	frames      map[uint64]*splitFrame
+	completed   uint64
+	completedAt time.Time
+	stats       SplitStats
 }
 
 func NewSplitDataReciever(childSize uint32, childNum uint32) *SplitDataReciever {
 	return &SplitDataReciever{
-		childSize: childSize,
-		buffer:    make([][]byte, childNum),
+		childSize:   childSize,
+		maxChildren: childNum,
+		frames:      make(map[uint64]*splitFrame, maxInFlightFrames),
 	}
 }
 
 func (sdr *SplitDataReciever) ReceiveDataFromUDP(conn *net.UDPConn) []byte {
-	bufferSize := uint32(len(sdr.buffer))
 	for {
 		// read data
 		buf := make([]byte, sdr.childSize)
@@ -96,29 +186,129 @@ func (sdr *SplitDataReciever) ReceiveDataFromUDP(conn *net.UDPConn) []byte {
 		if n == 0 || err != nil {
 			return nil
 		}
+		if data := sdr.Add(buf[:n], time.Now()); data != nil {
+			return data
+		}
+	}
+}
 
-		// decode and drop the invalid child
-		header, data := decodeChildPackage(buf[:n])
-		if header.number >= bufferSize {
-			continue
+// Add adds a child to its frame, the data of the frame is returned once
+// all of its children have arrived.
+func (sdr *SplitDataReciever) Add(child []byte, now time.Time) []byte {
+	sdr.expire(now)
+	if len(child) < childHeaderSize {
+		sdr.drop()
+		return nil
+	}
+
+	// decode and drop the invalid or outdated child
+	header, data := decodeChildPackage(child)
+	if header.total == 0 || header.total > sdr.maxChildren ||
+		header.number >= header.total {
+		sdr.drop()
+		return nil
+	}
+	if header.serialNumber <= sdr.completed && !sdr.restarted(header.serialNumber, now) {
+		sdr.drop()
+		return nil
+	}
+
+	frame := sdr.frames[header.serialNumber]
+	if frame == nil {
+		sdr.evict()
+		frame = &splitFrame{
+			children:  make([][]byte, header.total),
+			childLen:  -1,
+			createdAt: now,
 		}
+		sdr.frames[header.serialNumber] = frame
+	}
+	if uint32(len(frame.children)) != header.total ||
+		frame.children[header.number] != nil {
+		sdr.drop()
+		return nil
+	}
 
-		// drop the outdated package
-		if header.serialNumber < sdr.serialNumber {
-			continue
+	// every child except the last one has the same length
+	if header.number < header.total-1 {
+		if frame.childLen >= 0 && frame.childLen != len(data) {
+			sdr.drop()
+			return nil
+		}
+		frame.childLen = len(data)
+	}
+
+	frame.children[header.number] = data
+	frame.received += 1
+	if frame.received < header.total {
+		return nil
+	}
+
+	// the frames older than the completed one are useless
+	sdr.completed = header.serialNumber
+	sdr.completedAt = now
+	for serial := range sdr.frames {
+		if serial < sdr.completed {
+			atomic.AddUint64(&sdr.stats.Incomplete, 1)
+		}
+		if serial <= sdr.completed {
+			delete(sdr.frames, serial)
 		}
+	}
+	atomic.AddUint64(&sdr.stats.Completed, 1)
+	return bytes.Join(frame.children, []byte{})
+}
+
+// GetStats returns the counters of the reassembly, it is safe to call it
+// while receiving.
+func (sdr *SplitDataReciever) GetStats() SplitStats {
+	return SplitStats{
+		Completed:  atomic.LoadUint64(&sdr.stats.Completed),
+		Dropped:    atomic.LoadUint64(&sdr.stats.Dropped),
+		Incomplete: atomic.LoadUint64(&sdr.stats.Incomplete),
+	}
+}
+
+func (sdr *SplitDataReciever) drop() {
+	atomic.AddUint64(&sdr.stats.Dropped, 1)
+}
 
-		// skip the uncomplelted package
-		if header.serialNumber > sdr.serialNumber {
-			sdr.serialNumber = header.serialNumber
-			sdr.totalChildren = header.total
-			sdr.receivedChildren = 0
+func (sdr *SplitDataReciever) expire(now time.Time) {
+	for serial, frame := range sdr.frames {
+		if now.Sub(frame.createdAt) > frameExpiry {
+			delete(sdr.frames, serial)
+			atomic.AddUint64(&sdr.stats.Incomplete, 1)
 		}
+	}
+}
 
-		sdr.buffer[header.number] = data
-		sdr.receivedChildren += 1
-		if sdr.receivedChildren == sdr.totalChildren {
-			return bytes.Join(sdr.buffer[:sdr.totalChildren], []byte{})
+// restarted reports whether the sender restarted its serial numbers, as
+// the serial number jumps far back, or the old serial numbers keep coming
+// while no frame is completed for longer than the expiry. The reassembly
+// starts over then.
+func (sdr *SplitDataReciever) restarted(serial uint64, now time.Time) bool {
+	if sdr.completed-serial < serialRestartGap && now.Sub(sdr.completedAt) <= frameExpiry {
+		return false
+	}
+	for old := range sdr.frames {
+		delete(sdr.frames, old)
+		atomic.AddUint64(&sdr.stats.Incomplete, 1)
+	}
+	sdr.completed = 0
+	return true
+}
+
+// evict removes the oldest frame when the window is full.
+func (sdr *SplitDataReciever) evict() {
+	if len(sdr.frames) < maxInFlightFrames {
+		return
+	}
+	var oldest uint64
+	for serial := range sdr.frames {
+		if oldest == 0 || serial < oldest {
+			oldest = serial
 		}
 	}
+	delete(sdr.frames, oldest)
+	atomic.AddUint64(&sdr.stats.Incomplete, 1)
 }
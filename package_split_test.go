@@ -0,0 +1,129 @@
+package gosnake
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func splitTestFrame(serial uint64, data []byte, childDataSize int) (children [][]byte) {
+	total := (len(data) + childDataSize - 1) / childDataSize
+	for i := 0; i < total; i++ {
+		s, e := i*childDataSize, (i+1)*childDataSize
+		if e > len(data) {
+			e = len(data)
+		}
+		header := &childPackageHeader{
+			serialNumber: serial,
+			number:       uint32(i),
+			total:        uint32(total),
+		}
+		children = append(children, encodeChildPackage(header, data[s:e]))
+	}
+	return
+}
+
+func TestSplitDataRecieverInterleaved(t *testing.T) {
+	sdr := NewSplitDataReciever(childHeaderSize+4, 10)
+	now := time.Now()
+	data1 := []byte("frame one data")
+	data2 := []byte("frame two data!")
+	f1 := splitTestFrame(1, data1, 4)
+	f2 := splitTestFrame(2, data2, 4)
+
+	// a child of the newer frame does not discard the older one
+	order := [][]byte{f1[0], f2[3], f1[2], f2[0], f1[1], f2[1]}
+	for _, child := range order {
+		if data := sdr.Add(child, now); data != nil {
+			t.Fatalf("frame completed too early: %q", data)
+		}
+	}
+	if data := sdr.Add(f1[3], now); !bytes.Equal(data, data1) {
+		t.Errorf("got %q, want %q", data, data1)
+	}
+	if data := sdr.Add(f2[2], now); !bytes.Equal(data, data2) {
+		t.Errorf("got %q, want %q", data, data2)
+	}
+
+	// the children of the completed frames are outdated
+	sdr.Add(f1[0], now)
+	if stats := sdr.GetStats(); stats.Completed != 2 || stats.Dropped != 1 {
+		t.Errorf("got stats %+v", stats)
+	}
+}
+
+func TestSplitDataRecieverInvalidAndExpired(t *testing.T) {
+	sdr := NewSplitDataReciever(childHeaderSize+4, 3)
+	now := time.Now()
+	f := splitTestFrame(1, []byte("0123456789abcdef"), 4)
+	sdr.Add(f[0], now)
+	if stats := sdr.GetStats(); stats.Dropped != 1 {
+		t.Errorf("child of too many children is not dropped: %+v", stats)
+	}
+
+	f = splitTestFrame(2, []byte("0123456789"), 4)
+	sdr.Add(f[0], now)
+	sdr.Add(f[1], now.Add(2*frameExpiry))
+	if stats := sdr.GetStats(); stats.Incomplete != 1 {
+		t.Errorf("frame is not expired: %+v", stats)
+	}
+	if data := sdr.Add(f[2], now.Add(2*frameExpiry)); data != nil {
+		t.Errorf("expired frame is completed: %q", data)
+	}
+}
+
+func TestSplitDataRecieverRestart(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		name      string
+		completed uint64
+		restart   uint64
+		after     time.Duration
+		ok        bool
+	}{
+		{"reordered", 1000, 999, 0, false},
+		{"far back", 1 << 20, 1, 0, true},
+		{"old after expiry", 1000, 1, 2 * frameExpiry, true},
+		{"old within expiry", 1000, 1, frameExpiry / 2, false},
+	} {
+		sdr := NewSplitDataReciever(childHeaderSize+4, 10)
+		data := []byte("data")
+		sdr.Add(splitTestFrame(c.completed, data, 4)[0], now)
+		got := sdr.Add(splitTestFrame(c.restart, data, 4)[0], now.Add(c.after))
+		if (got != nil) != c.ok {
+			t.Errorf("%s: got %q, want accepted %v", c.name, got, c.ok)
+		}
+		// the frames after the restart go on
+		if c.ok && sdr.Add(splitTestFrame(c.restart+1, data, 4)[0], now.Add(c.after)) == nil {
+			t.Errorf("%s: the next frame is dropped", c.name)
+		}
+	}
+}
+
+func TestSplitDataRecieverLargestFrame(t *testing.T) {
+	options := DefaultServerOptions.RoomOptions.clone()
+	options.BorderWidth, options.BorderHeight = maxBorderSize, maxBorderSize
+	if err := options.Validate(); err != nil {
+		t.Fatalf("largest board is refused: %v", err)
+	}
+	// the largest frame fits at the smallest datagram size, a larger one
+	// is dropped
+	childDataSize := minDatagramSize - childHeaderSize
+	for _, c := range []struct {
+		size int
+		ok   bool
+	}{
+		{maxFrameSize, true},
+		{splitChildPackageNum*childDataSize + 1, false},
+	} {
+		sdr := NewSplitDataReciever(minDatagramSize, splitChildPackageNum)
+		data := bytes.Repeat([]byte{1}, c.size)
+		var got []byte
+		for _, child := range splitTestFrame(1, data, childDataSize) {
+			got = sdr.Add(child, time.Now())
+		}
+		if (got != nil) != c.ok {
+			t.Errorf("frame of %d bytes got reassembled %v, want %v", c.size, got != nil, c.ok)
+		}
+	}
+}
@@ -6,6 +6,11 @@ import (
 	"time"
 )
 
+const (
+	boostSpeed    = 2
+	maxInputDepth = 3
+)
+
 type Player struct {
 	snake *Snake
 
@@ -16,18 +21,59 @@ type Player struct {
 	score     uint16
 	lastRecv  time.Time
 	createdAt time.Time
+
+	// the round starts on the join and the replay, the cause and the
+	// killer are kept until the next round
+	roundStart time.Time
+	diedAt     time.Time
+	deathCause DeathCause
+	killerID   string
+	kills      int
+	deaths     int
+
+	protectedUntil time.Time
+
+	boost       bool
+	boostSteps  int
+	moveAcc     float64
+	speedEffect float64
+	speedUntil  time.Time
+
+	inputs   []playerInput
+	inputAck uint32
+	eventAck uint32
+
+	latency LatencyStats
+
+	datagramSize int
+
+	cmdLimit  *TokenBucket
+	chatLimit *TokenBucket
+}
+
+type playerInput struct {
+	dir Direction
+	seq uint32
 }
 
-func NewPlayer(addr *net.UDPAddr, playerID string, snakePosLimit Limit) (player *Player, err error) {
+func NewPlayer(addr *net.UDPAddr, playerID string, snake *Snake) *Player {
 	now := time.Now()
-	player = &Player{
-		id:        playerID,
-		addr:      addr,
-		lastRecv:  now,
-		createdAt: now,
+	return &Player{
+		snake:      snake,
+		id:         playerID,
+		addr:       addr,
+		lastRecv:   now,
+		createdAt:  now,
+		roundStart: now,
+		cmdLimit:   NewTokenBucket(playerCMDRate, playerCMDBurst),
+		chatLimit:  NewTokenBucket(chatRate, chatBurst),
 	}
-	player.snake = NewCenterPosSnake(snakePosLimit)
-	return
+}
+
+// AllowCMD reports whether the command of the player should be handled,
+// the commands over the rate are dropped.
+func (player *Player) AllowCMD(now time.Time) bool {
+	return player.cmdLimit.Allow(now, 1)
 }
 
 func (player *Player) GetID() string {
@@ -61,6 +107,26 @@ func (player *Player) IsSnakeTaken(pos Position) bool {
 	return player.snake.IsTaken(pos)
 }
 
+func (player *Player) GetSnakeHeadPos() Position {
+	return player.snake.GetHeadPos()
+}
+
+func (player *Player) GetSnakeLen() int {
+	return player.snake.Len()
+}
+
+// Before reports whether player takes precedence over other when they
+// compete for the same food item.
+func (player *Player) Before(other *Player) bool {
+	if player.GetSnakeLen() != other.GetSnakeLen() {
+		return player.GetSnakeLen() < other.GetSnakeLen()
+	}
+	if !player.createdAt.Equal(other.createdAt) {
+		return player.createdAt.Before(other.createdAt)
+	}
+	return player.id < other.id
+}
+
 func (player *Player) GetSnakeTailPos() Position {
 	return player.snake.GetTailPos()
 }
@@ -69,10 +135,27 @@ func (player *Player) GetSnakeDir() Direction {
 	return player.snake.GetDir()
 }
 
-func (player *Player) Reset(snakePosLimit Limit) {
-	player.snake = NewCenterPosSnake(snakePosLimit)
+func (player *Player) Reset(snake *Snake) {
+	player.snake = snake
+	player.boost = false
+	player.boostSteps = 0
+	player.moveAcc = 0
+	player.speedUntil = time.Time{}
+	player.inputs = player.inputs[:0]
 	player.UnPause()
 	player.UnOver()
+	player.roundStart = time.Now()
+	player.deathCause = ""
+	player.killerID = ""
+}
+
+// Protect keeps the player from colliding with other snakes for d.
+func (player *Player) Protect(d time.Duration) {
+	player.protectedUntil = time.Now().Add(d)
+}
+
+func (player *Player) IsProtected() bool {
+	return time.Now().Before(player.protectedUntil)
 }
 
 func (player *Player) GetSnakeNextHeadPos(dir Direction) *Position {
@@ -87,14 +170,166 @@ func (player *Player) UnPause() {
 	player.pause = false
 }
 
-func (player *Player) Over() {
+// Die ends the round of the player with the cause, the killer is empty
+// if the player is not killed by another snake.
+func (player *Player) Die(cause DeathCause, killerID string) {
 	player.over = true
+	player.diedAt = time.Now()
+	player.deathCause = cause
+	player.killerID = killerID
+	player.deaths += 1
+}
+
+// AddKill credits the player with the death of another snake.
+func (player *Player) AddKill() {
+	player.kills += 1
+}
+
+func (player *Player) GetKills() int {
+	return player.kills
 }
 
 func (player *Player) UnOver() {
 	player.over = false
 }
 
+// QueueInput buffers a turn for the coming steps, the turns which repeat
+// or reverse the last queued direction are dropped, as well as the turns
+// exceed the queue depth. A dropped turn is acknowledged together with the
+// last queued one.
+func (player *Player) QueueInput(dir Direction, seq uint32) bool {
+	n := len(player.inputs)
+	last := player.snake.GetDir()
+	if n > 0 {
+		last = player.inputs[n-1].dir
+	}
+	if dir == last || dir.Oppsite(last) || n >= maxInputDepth {
+		if n > 0 {
+			player.inputs[n-1].seq = seq
+		} else {
+			player.inputAck = seq
+		}
+		return false
+	}
+	player.inputs = append(player.inputs, playerInput{dir, seq})
+	return true
+}
+
+// NextDir pops the next queued turn, the current direction of the snake
+// is returned when no turn is queued.
+func (player *Player) NextDir() Direction {
+	if len(player.inputs) == 0 {
+		return player.snake.GetDir()
+	}
+	input := player.inputs[0]
+	player.inputs = player.inputs[1:]
+	player.inputAck = input.seq
+	return input.dir
+}
+
+// GetInputAck returns the sequence number of the last consumed input.
+func (player *Player) GetInputAck() uint32 {
+	return player.inputAck
+}
+
+// SetDatagramSize sets the negotiated size of the datagrams sent to
+// the player.
+func (player *Player) SetDatagramSize(size int) {
+	player.datagramSize = size
+}
+
+func (player *Player) GetDatagramSize() int {
+	return player.datagramSize
+}
+
+// UpdateLatency measures the round trip time with the server time echoed
+// by the client, the delay is the time the client held the echo.
+func (player *Player) UpdateLatency(echoServerTime, delay int64) {
+	if echoServerTime == 0 {
+		return
+	}
+	rtt := time.Now().UnixNano() - echoServerTime - delay
+	player.latency.Update(time.Duration(rtt))
+}
+
+func (player *Player) GetLatency() *LatencyStats {
+	return &player.latency
+}
+
+func (player *Player) GetSnakeData() *SnakeData {
+	sd := NewSnakeData(player.id, player.GetSnakeBody(), player.GetSnakeDir())
+	sd.Protected = player.IsProtected()
+	sd.Over = player.over
+	return sd
+}
+
+// GetSnakeBody returns the positions of the snake from head to tail.
+func (player *Player) GetSnakeBody() []Position {
+	return player.snake.GetBody()
+}
+
+func (player *Player) ToggleBoost() {
+	player.boost = !player.boost
+	player.boostSteps = 0
+}
+
+func (player *Player) GetBoost() bool {
+	return player.boost
+}
+
+// SetSpeedEffect multiplies the speed of the player by factor for d.
+func (player *Player) SetSpeedEffect(factor float64, d time.Duration) {
+	player.speedEffect = factor
+	player.speedUntil = time.Now().Add(d)
+}
+
+// GetSpeed returns the speed of the player relative to the normal speed.
+func (player *Player) GetSpeed() float64 {
+	speed := 1.0
+	if player.boost {
+		speed *= boostSpeed
+	}
+	if time.Now().Before(player.speedUntil) {
+		speed *= player.speedEffect
+	}
+	return speed
+}
+
+// Accelerate accumulates the movement of elapsed time, it reports whether
+// the accumulated movement reaches one step of interval. The snake moves
+// at most one step per call, so the movement left is kept below a step.
+func (player *Player) Accelerate(elapsed, interval time.Duration) bool {
+	player.moveAcc += float64(elapsed) * player.GetSpeed()
+	if player.moveAcc < float64(interval) {
+		return false
+	}
+	player.moveAcc -= float64(interval)
+	if player.moveAcc >= float64(interval) {
+		player.moveAcc = float64(interval) - 1
+	}
+	return true
+}
+
+func (player *Player) ResetAcc() {
+	player.moveAcc = 0
+}
+
+// ConsumeBoost charges the player for one boosted step, the snake loses
+// a node every cost steps and the boost stops when it can not shrink.
+func (player *Player) ConsumeBoost(cost int) {
+	if !player.boost || cost <= 0 {
+		return
+	}
+	player.boostSteps += 1
+	if player.boostSteps < cost {
+		return
+	}
+	player.boostSteps = 0
+	if !player.snake.Shrink() {
+		player.boost = false
+	}
+}
+
 func (player *Player) GetSnakeTakes() map[Position]struct{} {
 	return player.snake.GetTakes()
 }
@@ -113,18 +348,92 @@ type PlayerStat struct {
 	Score uint16
 	Pause bool
 	Over  bool
+	Boost bool
+	Kills int
+
+	LatencyMS int
 }
 
 type PlayerStats []*PlayerStat
 
+func (stat *PlayerStat) encode(w *wireWriter) {
+	w.String(stat.ID)
+	w.Uint(uint64(stat.Score))
+	w.Bool(stat.Pause)
+	w.Bool(stat.Over)
+	w.Bool(stat.Boost)
+	w.Uint(uint64(stat.Kills))
+	w.Uint(uint64(stat.LatencyMS))
+}
+
+func (stat *PlayerStat) decode(r *wireReader) {
+	stat.ID = r.String()
+	stat.Score = uint16(r.Uint())
+	stat.Pause = r.Bool()
+	stat.Over = r.Bool()
+	stat.Boost = r.Bool()
+	stat.Kills = int(r.Uint())
+	stat.LatencyMS = int(r.Uint())
+}
+
 func (player *Player) GetStat() *PlayerStat {
 	return &PlayerStat{
 		ID:    player.id,
 		Score: player.score,
 		Pause: player.pause,
 		Over:  player.over,
+		Boost: player.boost,
+		Kills: player.kills,
+
+		LatencyMS: int(player.latency.GetRTT() / time.Millisecond),
+	}
+}
+
+// PlayerStatus is the state of the player reported by the admin API.
+type PlayerStatus struct {
+	ID           string     `json:"id"`
+	RoomID       int        `json:"room_id"`
+	Addr         string     `json:"addr"`
+	Score        uint16     `json:"score"`
+	Length       int        `json:"length"`
+	Pause        bool       `json:"pause"`
+	Over         bool       `json:"over"`
+	Boost        bool       `json:"boost"`
+	Protected    bool       `json:"protected"`
+	Kills        int        `json:"kills"`
+	Deaths       int        `json:"deaths"`
+	DeathCause   DeathCause `json:"death_cause,omitempty"`
+	KillerID     string     `json:"killer_id,omitempty"`
+	LatencyMS    int        `json:"latency_ms"`
+	JitterMS     int        `json:"jitter_ms"`
+	DatagramSize int        `json:"datagram_size"`
+	JoinedAt     time.Time  `json:"joined_at"`
+	LastRecv     time.Time  `json:"last_recv"`
+}
+
+func (player *Player) GetStatus(roomID int) *PlayerStatus {
+	return &PlayerStatus{
+		ID:           player.id,
+		RoomID:       roomID,
+		Addr:         player.addr.String(),
+		Score:        player.score,
+		Length:       player.GetSnakeLen(),
+		Pause:        player.pause,
+		Over:         player.over,
+		Boost:        player.boost,
+		Protected:    player.IsProtected(),
+		Kills:        player.kills,
+		Deaths:       player.deaths,
+		DeathCause:   player.deathCause,
+		KillerID:     player.killerID,
+		LatencyMS:    int(player.latency.GetRTT() / time.Millisecond),
+		JitterMS:     int(player.latency.GetJitter() / time.Millisecond),
+		DatagramSize: player.datagramSize,
+		JoinedAt:     player.createdAt,
+		LastRecv:     player.lastRecv,
 	}
 }
+
 func (stats PlayerStats) Len() int {
 	return len(stats)
 }
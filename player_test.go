@@ -0,0 +1,154 @@
+package gosnake
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPlayerAccelerate(t *testing.T) {
+	interval := 300 * time.Millisecond
+	for _, c := range []struct {
+		name    string
+		elapsed time.Duration
+		boost   bool
+		ticks   int
+		want    int
+	}{
+		{"tick of the interval", interval, false, 6, 6},
+		{"fast tick", 50 * time.Millisecond, false, 12, 2},
+		{"fast tick boosted", 50 * time.Millisecond, true, 12, 4},
+		{"uneven tick", 70 * time.Millisecond, false, 9, 2},
+		// the snake moves once per tick however fast it is
+		{"tick of the interval boosted", interval, true, 6, 6},
+	} {
+		player := NewPlayer(nil, "p", NewSnake(0, 0, DirRight))
+		if c.boost {
+			player.ToggleBoost()
+		}
+		steps := 0
+		for i := 0; i < c.ticks; i++ {
+			if player.Accelerate(c.elapsed, interval) {
+				steps++
+			}
+		}
+		if steps != c.want {
+			t.Errorf("%s: got %d steps, want %d", c.name, steps, c.want)
+		}
+		if player.moveAcc >= float64(interval) {
+			t.Errorf("%s: got movement %v left over a step", c.name, time.Duration(player.moveAcc))
+		}
+	}
+}
+
+func TestPlayerAccelerateAfterBoost(t *testing.T) {
+	interval := 300 * time.Millisecond
+	player := NewPlayer(nil, "p", NewSnake(0, 0, DirRight))
+	player.ToggleBoost()
+	for i := 0; i < 100; i++ {
+		player.Accelerate(interval, interval)
+	}
+	player.ToggleBoost()
+	// at most one step is carried over from the boost
+	steps := 0
+	for i := 0; i < 4; i++ {
+		if player.Accelerate(interval/2, interval) {
+			steps++
+		}
+	}
+	if steps > 3 {
+		t.Errorf("got %d steps after the boost, want at most 3", steps)
+	}
+}
+
+func TestPlayerConsumeBoost(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		length    int
+		cost      int
+		boost     bool
+		steps     int
+		wantLen   int
+		wantBoost bool
+	}{
+		{"not boosted", 5, 2, false, 10, 5, false},
+		{"no cost", 5, 0, true, 10, 5, true},
+		{"below the cost", 5, 3, true, 2, 5, true},
+		{"every cost steps", 5, 3, true, 7, 3, true},
+		{"down to one node", 3, 1, true, 2, 1, true},
+		{"can not shrink", 3, 1, true, 3, 1, false},
+	} {
+		snake := NewSnake(0, 0, DirRight)
+		for snake.Len() < c.length {
+			snake.Move(DirRight)
+			snake.Grow()
+		}
+		player := NewPlayer(nil, "p", snake)
+		if c.boost {
+			player.ToggleBoost()
+		}
+		for i := 0; i < c.steps; i++ {
+			player.ConsumeBoost(c.cost)
+		}
+		if snake.Len() != c.wantLen || player.GetBoost() != c.wantBoost {
+			t.Errorf(
+				"%s: got length %d and boost %v, want %d and %v", c.name,
+				snake.Len(), player.GetBoost(), c.wantLen, c.wantBoost,
+			)
+		}
+	}
+}
+
+func TestSnakeShrink(t *testing.T) {
+	snake := NewBodySnake([]Position{{2, 0}, {1, 0}, {0, 0}}, DirRight)
+	for _, want := range []struct {
+		ok   bool
+		body []Position
+	}{
+		{true, []Position{{2, 0}, {1, 0}}},
+		{true, []Position{{2, 0}}},
+		{false, []Position{{2, 0}}},
+	} {
+		if ok := snake.Shrink(); ok != want.ok {
+			t.Errorf("got shrink %v, want %v", ok, want.ok)
+		}
+		body := snake.GetBody()
+		if len(body) != len(want.body) || snake.Len() != len(want.body) {
+			t.Fatalf("got body %v, want %v", body, want.body)
+		}
+		for i, pos := range want.body {
+			if body[i] != pos {
+				t.Errorf("got body %v, want %v", body, want.body)
+			}
+		}
+		if snake.IsTaken(Position{0, 0}) {
+			t.Error("the shrunk tail is still taken")
+		}
+	}
+	// the snake grows back to the shrunk tail
+	snake.Move(DirRight)
+	snake.Grow()
+	if snake.Len() != 2 {
+		t.Errorf("got length %d after the growth, want 2", snake.Len())
+	}
+}
+
+func TestTickInterval(t *testing.T) {
+	for _, c := range []struct {
+		tick, move int
+		want       time.Duration
+	}{
+		{50, 300, 50 * time.Millisecond},
+		{0, 300, 300 * time.Millisecond},
+		{-1, 300, 300 * time.Millisecond},
+		// the tick is never slower than the move
+		{500, 300, 300 * time.Millisecond},
+	} {
+		options := *DefaultServerOptions.RoomOptions
+		options.TickIntervalMS, options.AutoMoveIntervalMS = c.tick, c.move
+		room := NewRoom(0, &options, func([]byte, *net.UDPAddr, int) {})
+		if got := room.getTickInterval(); got != c.want {
+			t.Errorf("tick %d and move %d got interval %v, want %v", c.tick, c.move, got, c.want)
+		}
+	}
+}
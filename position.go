@@ -3,3 +3,52 @@ package gosnake
 type Position struct {
 	X, Y int
 }
+
+func (pos Position) Next(dir Direction) Position {
+	switch dir {
+	case DirUp:
+		pos.Y -= 1
+	case DirRight:
+		pos.X += 1
+	case DirDown:
+		pos.Y += 1
+	case DirLeft:
+		pos.X -= 1
+	}
+	return pos
+}
+
+func (pos Position) Distance(other Position) int {
+	dx, dy := pos.X-other.X, pos.Y-other.Y
+	return IfInt(dx < 0, -dx, dx) + IfInt(dy < 0, -dy, dy)
+}
+
+type Taker interface {
+	IsTaken(Position) bool
+}
+
+type Takers []Taker
+
+func (ts Takers) IsTaken(pos Position) bool {
+	for _, t := range ts {
+		if t.IsTaken(pos) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFreePositions returns the positions inside limit which are not
+// taken by any of the takers.
+func GetFreePositions(limit Limit, takers ...Taker) (free []Position) {
+	ts := Takers(takers)
+	for y := limit.MinY; y <= limit.MaxY; y++ {
+		for x := limit.MinX; x <= limit.MaxX; x++ {
+			pos := Position{x, y}
+			if !ts.IsTaken(pos) {
+				free = append(free, pos)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,136 @@
+package gosnake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	postMortemWidth = 48
+	postMortemColor = "1;41;37"
+)
+
+// DeathData tells the dead player how the round ended, it is sent once
+// to the player when the snake dies.
+type DeathData struct {
+	Cause    DeathCause
+	KillerID string
+	Length   int
+	Score    int
+	Kills    int
+	Survival time.Duration
+}
+
+// GetDeathData returns the end of the round of the player, nil is
+// returned if the snake is alive.
+func (player *Player) GetDeathData() *DeathData {
+	if !player.over {
+		return nil
+	}
+	return &DeathData{
+		Cause:    player.deathCause,
+		KillerID: player.killerID,
+		Length:   player.GetSnakeLen(),
+		Score:    int(player.score),
+		Kills:    player.kills,
+		Survival: player.diedAt.Sub(player.roundStart),
+	}
+}
+
+func (death *DeathData) encode(w *wireWriter) {
+	w.String(string(death.Cause))
+	w.String(death.KillerID)
+	w.Uint(uint64(death.Length))
+	w.Int(int64(death.Score))
+	w.Uint(uint64(death.Kills))
+	w.Int(int64(death.Survival))
+}
+
+func (death *DeathData) decode(r *wireReader) {
+	death.Cause = DeathCause(r.String())
+	death.KillerID = r.String()
+	death.Length = int(r.Uint())
+	death.Score = int(r.Int())
+	death.Kills = int(r.Uint())
+	death.Survival = time.Duration(r.Int())
+}
+
+// describeDeath returns how the snake died, the killer is empty if the
+// snake is not killed by another one.
+func describeDeath(cause DeathCause, killer string) string {
+	switch cause {
+	case DeathWall:
+		return "crashed into the wall"
+	case DeathSelf:
+		return "crashed into its own body"
+	case DeathBody:
+		if killer != "" {
+			return "crashed into the body of " + killer
+		}
+		return "crashed into a body"
+	case DeathHeadOn:
+		if killer != "" {
+			return "crashed head on into " + killer
+		}
+		return "crashed head on"
+	}
+	return "died"
+}
+
+func (client *Client) handleDeath(death *DeathData) {
+	logger(LogClient).Info("snake died", "cause", death.Cause, "killer", death.KillerID)
+	client.death = death
+	client.updateFrame()
+}
+
+// checkReplayed drops the post-mortem once the snake of the player is
+// alive in the snapshot again.
+func (client *Client) checkReplayed(sceneData *SceneData) {
+	if snake := sceneData.GetPlayerSnake(); snake != nil && !snake.Over {
+		client.death = nil
+	}
+}
+
+// getPostMortemTexts returns the overlay shown in the middle of the board
+// of the size, the lines above the overlay are empty.
+func (client *Client) getPostMortemTexts(death *DeathData, boardWidth, boardHeight int) (texts Lines, left int) {
+	width := postMortemWidth
+	if width > boardWidth-4 {
+		width = boardWidth - 4
+	}
+	lines := []string{
+		"",
+		"GAME OVER",
+		"",
+		"you " + describeDeath(death.Cause, death.KillerID),
+		fmt.Sprintf("length %d   score %d   kills %d", death.Length, death.Score, death.Kills),
+		"survived " + death.Survival.Round(100*time.Millisecond).String(),
+		"",
+		"press r to replay",
+		"",
+	}
+	top := (boardHeight - len(lines)) / 2
+	if top < 0 {
+		top = 0
+	}
+	texts = make(Lines, top, top+len(lines))
+	for _, line := range lines {
+		texts = append(texts, fmt.Sprintf(
+			"\033[%sm%s\033[0m", postMortemColor, centerText(line, width),
+		))
+	}
+	return texts, (boardWidth - width) / 2
+}
+
+// centerText pads the text to the width, the text longer than the width
+// is truncated.
+func centerText(text string, width int) string {
+	n := utf8.RuneCountInString(text)
+	if n > width {
+		return string([]rune(text)[:width])
+	}
+	left := (width - n) / 2
+	return strings.Repeat(" ", left) + text + strings.Repeat(" ", width-n-left)
+}
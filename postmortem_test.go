@@ -0,0 +1,78 @@
+package gosnake
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPlayerDie(t *testing.T) {
+	deaths := map[string]int{}
+	room := NewRoom(0, DefaultServerOptions.RoomOptions, func(data []byte, addr *net.UDPAddr, _ int) {
+		if serverData, err := DecodeServerData(data); err == nil && serverData.Death != nil {
+			deaths[addr.String()]++
+		}
+	})
+	room.Init()
+	a := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	b := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2}
+	for _, addr := range []*net.UDPAddr{a, b} {
+		room.handleData(&RoomData{Sender: addr, ClientData: &ClientData{CMD: CMDJoin}})
+	}
+	victim, killer := room.players[a.String()], room.players[b.String()]
+	if victim.GetDeathData() != nil {
+		t.Fatal("got death data of the living snake")
+	}
+
+	room.playerDie(victim, &death{cause: DeathBody, killer: killer})
+	data := victim.GetDeathData()
+	if data == nil || data.Cause != DeathBody || data.KillerID != b.String() || data.Length != victim.GetSnakeLen() {
+		t.Fatalf("got death data %+v", data)
+	}
+	if killer.GetKills() != 1 || room.GetStats().Kills != 1 || room.GetStats().Deaths[DeathBody] != 1 {
+		t.Errorf("got %d kills of the killer and %d of the room", killer.GetKills(), room.GetStats().Kills)
+	}
+	status := victim.GetStatus(0)
+	if status.Deaths != 1 || status.DeathCause != DeathBody || status.KillerID != b.String() {
+		t.Errorf("got status %+v", status)
+	}
+	// the post-mortem is sent once to the dead player only
+	room.sendAllPlayersData()
+	room.writer.flush()
+	room.sendAllPlayersData()
+	room.writer.flush()
+	if deaths[a.String()] != 1 || deaths[b.String()] != 0 {
+		t.Errorf("got post-mortems %v, want one to the dead player", deaths)
+	}
+	event := room.events[len(room.events)-1]
+	if event.Kind != EventDeath || event.OtherID != b.String() {
+		t.Errorf("got event %+v", event)
+	}
+
+	// the snake hitting itself is not a kill
+	room.playerDie(killer, &death{cause: DeathHeadOn, killer: killer})
+	if killer.GetKills() != 1 || killer.GetDeathData().KillerID != "" {
+		t.Errorf("got %d kills for the suicide", killer.GetKills())
+	}
+
+	room.handleData(&RoomData{Sender: a, ClientData: &ClientData{CMD: CMDReplay}})
+	if victim.GetDeathData() != nil || victim.GetStatus(0).DeathCause != "" {
+		t.Error("the death is kept after the replay")
+	}
+}
+
+func TestCenterText(t *testing.T) {
+	for _, c := range []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"ab", 6, "  ab  "},
+		{"abc", 6, " abc  "},
+		{"abcdef", 4, "abcd"},
+		{"", 2, "  "},
+	} {
+		if got := centerText(c.text, c.width); got != c.want {
+			t.Errorf("centerText(%q, %d) = %q, want %q", c.text, c.width, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package gosnake
+
+// correctionFrames is how many frames a corrected prediction takes to blend
+// into the new one before it snaps.
+const correctionFrames = 3
+
+type predictedInput struct {
+	seq uint32
+	dir Direction
+}
+
+// Predictor runs the movement rules of the server for the player snake on
+// the client, so the turns are shown before the server applies them. The
+// prediction is rebuilt from every authoritative snapshot and runs one input
+// ahead at most, as the server applies one input per move. A misprediction
+// is corrected by the next snapshot and blended over a few frames.
+type Predictor struct {
+	seq         uint32
+	tick        uint64
+	pending     []predictedInput
+	snake       *Snake
+	shown       *Snake
+	blend       int
+	corrections int
+}
+
+func NewPredictor() *Predictor {
+	return &Predictor{}
+}
+
+// Input records a turn and returns its sequence number, ok is false when
+// the turn repeats or reverses the last predicted direction.
+func (p *Predictor) Input(dir Direction) (seq uint32, ok bool) {
+	if n := len(p.pending); n > 0 {
+		last := p.pending[n-1].dir
+		if dir == last || dir.Oppsite(last) {
+			return
+		}
+	} else if p.snake != nil {
+		last := p.snake.GetDir()
+		if dir == last || dir.Oppsite(last) {
+			return
+		}
+	}
+	p.seq += 1
+	p.pending = append(p.pending, predictedInput{p.seq, dir})
+	return p.seq, true
+}
+
+// Reconcile drops the inputs acknowledged by the snapshot and applies the
+// next one on the authoritative snake, the outdated snapshots are ignored.
+func (p *Predictor) Reconcile(scene *SceneData) {
+	own := scene.GetPlayerSnake()
+	if scene.Tick < p.tick || own == nil {
+		return
+	}
+	p.tick = scene.Tick
+	i := 0
+	for i < len(p.pending) && p.pending[i].seq <= scene.InputAck {
+		i++
+	}
+	p.pending = p.pending[i:]
+
+	prev := p.getShown()
+	p.snake = NewBodySnake(own.GetBody(), own.Dir)
+	if !own.Over && len(p.pending) > 0 {
+		p.advance(scene, p.pending[0].dir)
+	}
+	if prev == nil || own.Over {
+		p.shown, p.blend = nil, 0
+		return
+	}
+	if p.snake.GetHeadPos().Distance(prev.GetHeadPos()) <= 1 {
+		p.shown, p.blend = nil, 0
+		return
+	}
+	// a correction arriving while blending keeps blending from the shown snake
+	if p.shown == nil {
+		p.corrections += 1
+		p.shown, p.blend = prev, correctionFrames
+	}
+}
+
+// advance moves the snake one step unless the step would kill it.
+func (p *Predictor) advance(scene *SceneData, dir Direction) {
+	limit := Limit{
+		MinX: 1, MaxX: scene.BorderWidth - 2,
+		MinY: 1, MaxY: scene.BorderHeight - 2,
+	}
+	others := make(map[Position]struct{})
+	for _, sd := range scene.Snakes {
+		if sd.ID == scene.PlayerID {
+			continue
+		}
+		for _, pos := range sd.GetBody() {
+			others[pos] = struct{}{}
+		}
+	}
+	next := p.snake.GetNextHeadPos(dir)
+	if next == nil || !limit.Contains(*next) {
+		return
+	}
+	if _, ok := others[*next]; ok {
+		return
+	}
+	if p.snake.IsTaken(*next) && *next != p.snake.GetTailPos() {
+		return
+	}
+	p.snake.Move(dir)
+	if scene.Food.IsTaken(*next) {
+		p.snake.Grow()
+	}
+}
+
+// Blend moves the shown snake one cell toward a corrected prediction, it is
+// called once per frame and returns whether the shown snake changed. The
+// shown snake snaps to the prediction when it is not caught up in time.
+func (p *Predictor) Blend() bool {
+	if p.shown == nil {
+		return false
+	}
+	p.blend -= 1
+	head, target := p.shown.GetHeadPos(), p.snake.GetHeadPos()
+	if p.blend <= 0 || head.Distance(target) <= 1 {
+		p.shown = nil
+		return true
+	}
+	dx, dy := target.X-head.X, target.Y-head.Y
+	dirs := []Direction{DirLeft, DirUp}
+	if dx > 0 {
+		dirs[0] = DirRight
+	}
+	if dy > 0 {
+		dirs[1] = DirDown
+	}
+	if IfInt(dy < 0, -dy, dy) > IfInt(dx < 0, -dx, dx) {
+		dirs[0], dirs[1] = dirs[1], dirs[0]
+	}
+	for _, dir := range dirs {
+		if next := p.shown.GetNextHeadPos(dir); next != nil && next.Distance(target) < head.Distance(target) {
+			p.shown.Move(dir)
+			return true
+		}
+	}
+	p.shown = nil
+	return true
+}
+
+func (p *Predictor) getShown() *Snake {
+	if p.shown != nil {
+		return p.shown
+	}
+	return p.snake
+}
+
+// GetSnakeData returns the shown player snake, nil is returned before the
+// first snapshot.
+func (p *Predictor) GetSnakeData(id string) *SnakeData {
+	shown := p.getShown()
+	if shown == nil {
+		return nil
+	}
+	return NewSnakeData(id, shown.GetBody(), shown.GetDir())
+}
+
+// GetCorrections returns how many times the prediction has been corrected.
+func (p *Predictor) GetCorrections() int {
+	return p.corrections
+}
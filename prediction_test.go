@@ -0,0 +1,149 @@
+package gosnake
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestScene(tick uint64, ack uint32, dir Direction, body ...Position) *SceneData {
+	return &SceneData{
+		PlayerID:     "a",
+		BorderWidth:  10,
+		BorderHeight: 10,
+		Snakes:       []*SnakeData{NewSnakeData("a", body, dir)},
+		Food:         NewCompressLayer(10, 10),
+		Tick:         tick,
+		InputAck:     ack,
+	}
+}
+
+func TestPredictorReconcile(t *testing.T) {
+	p := NewPredictor()
+	p.Reconcile(newTestScene(1, 0, DirRight, Position{4, 5}, Position{3, 5}))
+	if _, ok := p.Input(DirLeft); ok {
+		t.Errorf("reversed input is accepted")
+	}
+	seq, ok := p.Input(DirUp)
+	if !ok {
+		t.Fatalf("turn input is dropped")
+	}
+
+	// the turn is shown before the server applies it
+	p.Reconcile(newTestScene(1, 0, DirRight, Position{4, 5}, Position{3, 5}))
+	if ok := hasPos(p.GetSnakeData("a").GetBody(), Position{4, 4}); !ok {
+		t.Errorf("predicted head is not at {4 4}: %v", p.GetSnakeData("a").GetBody())
+	}
+
+	// the server applied the turn
+	p.Reconcile(newTestScene(2, seq, DirUp, Position{4, 4}, Position{4, 5}))
+	if ok := hasPos(p.GetSnakeData("a").GetBody(), Position{4, 4}); !ok || len(p.pending) != 0 {
+		t.Errorf("prediction is not reconciled: %v", p.GetSnakeData("a").GetBody())
+	}
+	if p.GetCorrections() != 0 {
+		t.Errorf("got %d corrections, want 0", p.GetCorrections())
+	}
+
+	// the outdated snapshot is ignored
+	p.Reconcile(newTestScene(1, 0, DirRight, Position{4, 5}, Position{3, 5}))
+	if ok := hasPos(p.GetSnakeData("a").GetBody(), Position{4, 4}); !ok {
+		t.Errorf("outdated snapshot is applied: %v", p.GetSnakeData("a").GetBody())
+	}
+}
+
+func TestPredictorOneInputPerMove(t *testing.T) {
+	p := NewPredictor()
+	p.Reconcile(newTestScene(1, 0, DirRight, Position{4, 5}, Position{3, 5}))
+	seq, _ := p.Input(DirUp)
+	p.Input(DirLeft)
+
+	// the second turn waits for the server to apply the first one
+	p.Reconcile(newTestScene(2, 0, DirRight, Position{4, 5}, Position{3, 5}))
+	if head := p.GetSnakeData("a").GetBody()[0]; head != (Position{4, 4}) {
+		t.Errorf("got predicted head %v, want {4 4}", head)
+	}
+	p.Reconcile(newTestScene(3, seq, DirUp, Position{4, 4}, Position{4, 5}))
+	if head := p.GetSnakeData("a").GetBody()[0]; head != (Position{3, 4}) {
+		t.Errorf("got predicted head %v, want {3 4}", head)
+	}
+}
+
+func TestPredictorBlend(t *testing.T) {
+	p := NewPredictor()
+	p.Reconcile(newTestScene(1, 0, DirRight, Position{4, 5}, Position{3, 5}))
+	p.Reconcile(newTestScene(2, 0, DirRight, Position{8, 5}, Position{7, 5}))
+	if p.GetCorrections() != 1 {
+		t.Errorf("got %d corrections, want 1", p.GetCorrections())
+	}
+	heads := []Position{p.GetSnakeData("a").GetBody()[0]}
+	for p.Blend() {
+		heads = append(heads, p.GetSnakeData("a").GetBody()[0])
+	}
+	// the shown snake walks toward the correction, then snaps to it
+	want := []Position{{4, 5}, {5, 5}, {6, 5}, {8, 5}}
+	if fmt.Sprint(heads) != fmt.Sprint(want) {
+		t.Errorf("got shown heads %v, want %v", heads, want)
+	}
+}
+
+func hasPos(positions []Position, pos Position) bool {
+	for _, p := range positions {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPlayerQueueInput(t *testing.T) {
+	type input struct {
+		dir Direction
+		ok  bool
+	}
+	for _, c := range []struct {
+		name     string
+		inputs   []input
+		wantDirs []Direction
+		wantAck  uint32
+	}{
+		{"turn", []input{{DirUp, true}}, []Direction{DirUp}, 1},
+		{"same direction", []input{{DirRight, false}}, nil, 1},
+		{"reversal", []input{{DirLeft, false}}, nil, 1},
+		{
+			"reversal of the queued turn",
+			[]input{{DirUp, true}, {DirDown, false}},
+			[]Direction{DirUp}, 2,
+		},
+		{
+			"turn back",
+			[]input{{DirUp, true}, {DirLeft, true}, {DirDown, true}},
+			[]Direction{DirUp, DirLeft, DirDown}, 3,
+		},
+		{
+			"overflow",
+			[]input{{DirUp, true}, {DirLeft, true}, {DirDown, true}, {DirRight, false}},
+			[]Direction{DirUp, DirLeft, DirDown}, 4,
+		},
+	} {
+		player := NewPlayer(nil, "a", NewSnake(5, 5, DirRight))
+		for i, in := range c.inputs {
+			if ok := player.QueueInput(in.dir, uint32(i+1)); ok != in.ok {
+				t.Errorf("%s: input %d got %v, want %v", c.name, i+1, ok, in.ok)
+			}
+		}
+		if len(player.inputs) > maxInputDepth {
+			t.Errorf("%s: got %d inputs queued", c.name, len(player.inputs))
+		}
+		for _, want := range c.wantDirs {
+			if dir := player.NextDir(); dir != want {
+				t.Errorf("%s: got direction %v, want %v", c.name, dir, want)
+			}
+		}
+		// the dropped inputs are acked with the last queued one
+		if ack := player.GetInputAck(); ack != c.wantAck {
+			t.Errorf("%s: got ack %d, want %d", c.name, ack, c.wantAck)
+		}
+		if dir := player.NextDir(); dir != DirRight {
+			t.Errorf("%s: got direction %v without an input, want the snake direction", c.name, dir)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gosnake
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var errServerNotRunning = errors.New("server is not running")
+
+// Reload applies the options to the running server without dropping the
+// games. The configured rooms get their new options once they are empty,
+// the new rooms are started and the rooms missing in the options are
+// retired. The listen address and the datagram size need a restart.
+func (s *Server) Reload(options *ServerOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	options = options.clone()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return errServerNotRunning
+	}
+	if s.IsDraining() {
+		return errServerDraining
+	}
+	if options.Addr != s.options.Addr {
+		logger(LogServer).Warn("listen_addr needs a restart", "listen_addr", options.Addr)
+		options.Addr = s.options.Addr
+	}
+	if options.MaxDatagramSize != s.options.MaxDatagramSize {
+		logger(LogServer).Warn(
+			"max_datagram_size needs a restart",
+			"max_datagram_size", options.MaxDatagramSize,
+		)
+		options.MaxDatagramSize = s.options.MaxDatagramSize
+	}
+	if options.KeyFile != s.options.KeyFile {
+		logger(LogServer).Warn("key_file needs a restart", "key_file", options.KeyFile)
+		options.KeyFile = s.options.KeyFile
+	}
+	if options.AdminAddr != s.options.AdminAddr || options.AdminToken != s.options.AdminToken {
+		logger(LogServer).Warn("admin_addr and admin_token need a restart")
+		options.AdminAddr = s.options.AdminAddr
+		options.AdminToken = s.options.AdminToken
+	}
+	if options.MaxRooms != s.options.MaxRooms {
+		logger(LogServer).Info(
+			"max_rooms changed", "old", s.options.MaxRooms, "new", options.MaxRooms,
+		)
+	}
+	for _, change := range diffRoomOptions(s.options.RoomOptions, options.RoomOptions) {
+		logger(LogServer).Info("room_options changed", "change", change)
+	}
+
+	oldRooms, newRooms := s.options.GetRooms(), options.GetRooms()
+	configured := make([]*Room, 0, len(newRooms))
+	for i, roomOptions := range newRooms {
+		if i >= len(s.configured) {
+			room := s.startRoom(roomOptions, false)
+			configured = append(configured, room)
+			logger(LogServer).Info("room added", "config_room", i, "room", room.GetID())
+			continue
+		}
+		room := s.configured[i]
+		configured = append(configured, room)
+		changes := diffRoomOptions(oldRooms[i], roomOptions)
+		if len(changes) == 0 {
+			continue
+		}
+		room.Reload(roomOptions)
+		logger(LogServer).Info(
+			"room changed, applied once empty",
+			"room", room.GetID(), "changes", strings.Join(changes, ", "),
+		)
+	}
+	for i := len(newRooms); i < len(s.configured); i++ {
+		room := s.configured[i]
+		room.Retire()
+		logger(LogServer).Info("room retired", "room", room.GetID())
+	}
+	s.configured = configured
+	s.options = *options
+	return nil
+}
+
+// diffRoomOptions describes the changed options by their names in the
+// configuration file, the secrets are not printed.
+func diffRoomOptions(old, new *RoomOptions) (changes []string) {
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		a := fmt.Sprint(oldValue.Field(i).Interface())
+		b := fmt.Sprint(newValue.Field(i).Interface())
+		if a == b {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch name {
+		case "password", "invite_code":
+			changes = append(changes, name+" changed")
+		default:
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", name, a, b))
+		}
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package gosnake
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffRoomOptions(t *testing.T) {
+	old := DefaultServerOptions.RoomOptions.clone()
+	new := old.clone()
+	new.BorderWidth = 24
+	new.Password = "secret"
+	new.SpawnPoints = []Position{}
+	want := []string{"border_width 32 -> 24", "password changed"}
+	if got := diffRoomOptions(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+}
+
+func TestServerReload(t *testing.T) {
+	options := DefaultServerOptions.clone()
+	options.Addr = "127.0.0.1:0"
+	options.RoomSize = 2
+	server := NewServer(options)
+	if err := server.Reload(options); err != errServerNotRunning {
+		t.Errorf("got %v reloading the stopped server", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx)
+	for len(server.getRooms()) < options.RoomSize {
+		time.Sleep(time.Millisecond)
+	}
+
+	changed, removed := server.getRoom(0), server.getRoom(1)
+	player := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	send := func(cmd CMD) {
+		changed.Call(func() {
+			changed.handleData(&RoomData{Sender: player, ClientData: &ClientData{CMD: cmd}})
+		})
+	}
+	borderWidth := func() (width int) {
+		changed.Call(func() {
+			width = changed.options.BorderWidth
+		})
+		return
+	}
+	send(CMDJoin)
+
+	reloaded := options.clone()
+	reloaded.RoomSize = 0
+	room := reloaded.RoomOptions.clone()
+	room.BorderWidth = 24
+	reloaded.Rooms = []*RoomOptions{room}
+	if err := server.Reload(reloaded); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-removed.Done():
+	case <-time.After(time.Second):
+		t.Error("removed room is not stopped")
+	}
+	if !removed.IsRetired() || changed.IsRetired() {
+		t.Errorf("got retired %v and %v, want the removed room only", removed.IsRetired(), changed.IsRetired())
+	}
+	if got := borderWidth(); got != options.RoomOptions.BorderWidth {
+		t.Errorf("got border width %d with a player, want the old %d", got, options.RoomOptions.BorderWidth)
+	}
+
+	send(CMDQuit)
+	if got := borderWidth(); got != 24 {
+		t.Errorf("got border width %d once empty, want 24", got)
+	}
+}
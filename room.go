@@ -2,71 +2,201 @@ package gosnake
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
-	"fmt"
 	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const clearPlayerTimeInterval = 10 * time.Second
+const (
+	clearPlayerTimeInterval = 10 * time.Second
+	roomDataChanSize        = 64
+	roomExecChanSize        = 8
+	goldenFoodSpeed         = 1.5
+	goldenFoodDuration      = 5 * time.Second
+)
+
+var (
+	errRoomFull    = errors.New("room is full")
+	errRoomRetired = errors.New("room is closed")
+)
 
 type RoomOptions struct {
 	BorderWidth        int `json:"border_width"`
 	BorderHeight       int `json:"border_height"`
 	AutoMoveIntervalMS int `json:"auto_move_interval_ms"`
+	TickIntervalMS     int `json:"tick_interval_ms"`
+	BoostCostSteps     int `json:"boost_cost_steps"`
 	PlayerSize         int `json:"player_size"`
+	FoodNum            int `json:"food_num"`
+	FoodPerPlayer      int `json:"food_per_player"`
+
+	FoodSpawn FoodSpawnStrategy `json:"food_spawn"`
+	// GoldenFoodPercent of the food items are golden, a snake which eats
+	// one is faster for a while.
+	GoldenFoodPercent int `json:"golden_food_percent"`
+
+	SpawnPoints       []Position `json:"spawn_points"`
+	SpawnRunway       int        `json:"spawn_runway"`
+	SpawnProtectionMS int        `json:"spawn_protection_ms"`
+
+	CollisionRule CollisionRule `json:"collision_rule"`
+
+	MaxClientBandwidth int  `json:"max_client_bandwidth"`
+	RequireSecure      bool `json:"require_secure"`
+
+	Password   string `json:"password"`
+	InviteCode string `json:"invite_code"`
+}
+
+// IsPrivate reports whether joining the room needs a secret.
+func (options *RoomOptions) IsPrivate() bool {
+	return options.Password != "" || options.InviteCode != ""
+}
+
+// CheckSecret reports whether the password or the invite code matches
+// the one of the room, the public rooms accept anything.
+func (options *RoomOptions) CheckSecret(password, inviteCode string) bool {
+	if !options.IsPrivate() {
+		return true
+	}
+	return (options.Password != "" && secretEqual(options.Password, password)) ||
+		(options.InviteCode != "" && secretEqual(options.InviteCode, inviteCode))
+}
+
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
 
 type Room struct {
-	options            RoomOptions
+	id int
+
+	// options are changed by the room only, the other goroutines read
+	// them with the lock
+	options   RoomOptions
+	optionsMu sync.RWMutex
+	pending   *RoomOptions
+	retired   int32
+
 	players            map[string]*Player
 	border             *RecBorder
-	food               *Food
+	food               *FoodManager
 	autoticker         *time.Ticker
 	clearPlayersTicker *time.Ticker
 	dataChan           chan *RoomData
-	sendData           func([]byte, *net.UDPAddr)
+	execChan           chan func()
+	done               chan struct{}
+	writer             *roomWriter
 	posLimit           Limit
+	tick               uint64
+	dirty              bool
+	paused             bool
+	events             []*GameEvent
+	eventSeq           uint32
+	playerNum          int32
+	limitedCMDs        uint64
+	idleRetire         time.Duration
+	emptySince         time.Time
+	created            bool
+	counters           roomCounters
+}
+
+// roomCounters are updated by the room and read by the other goroutines.
+type roomCounters struct {
+	ticks     uint64
+	tickNanos uint64
+	foodEaten uint64
+	kills     uint64
+	deaths    map[DeathCause]*uint64
 }
 
-func NewRoom(options *RoomOptions, sendData func([]byte, *net.UDPAddr)) *Room {
+func NewRoom(id int, options *RoomOptions, sendData sendFunc) *Room {
+	deaths := make(map[DeathCause]*uint64, len(deathCauses))
+	for _, cause := range deathCauses {
+		deaths[cause] = new(uint64)
+	}
 	return &Room{
-		options:  *options,
-		sendData: sendData,
-		posLimit: Limit{
-			MinX: 1, MaxX: options.BorderWidth - 2,
-			MinY: 1, MaxY: options.BorderHeight - 2,
-		},
+		id:      id,
+		options: *options,
+		writer:  newRoomWriter(sendData, options.MaxClientBandwidth),
+		// the data may be passed before the room runs
+		dataChan: make(chan *RoomData, roomDataChanSize),
+		execChan: make(chan func(), roomExecChanSize),
+		done:     make(chan struct{}),
+		posLimit: options.getPosLimit(),
+		counters: roomCounters{deaths: deaths},
 	}
 }
 
-func (room *Room) Init() {
-	// new border
-	room.border = NewRecBorder(
-		room.options.BorderWidth, room.options.BorderHeight,
-		"",
-	)
+func (room *Room) GetID() int {
+	return room.id
+}
 
-	// new food
-	room.food = NewFood(room.posLimit)
+// GetInfo returns the lobby information of the room, it is safe to call
+// it from other goroutines.
+func (room *Room) GetInfo() *RoomInfo {
+	room.optionsMu.RLock()
+	defer room.optionsMu.RUnlock()
+	info := &RoomInfo{
+		ID:         room.id,
+		PlayerSize: room.options.PlayerSize,
+		Private:    room.options.IsPrivate(),
+	}
+	if !info.Private {
+		info.Players = int(atomic.LoadInt32(&room.playerNum))
+	}
+	return info
+}
+
+// GetInviteCode returns the invite code of the room, it is safe to call
+// it from other goroutines.
+func (room *Room) GetInviteCode() string {
+	room.optionsMu.RLock()
+	defer room.optionsMu.RUnlock()
+	return room.options.InviteCode
+}
+
+func (room *Room) Init() {
+	// new border and food manager
+	room.resetScene()
 
 	// create auto move ticker
-	room.autoticker = time.NewTicker(time.Duration(room.options.AutoMoveIntervalMS) * time.Millisecond)
+	room.autoticker = time.NewTicker(room.getTickInterval())
 
 	// create clear disconnected players ticker
 	room.clearPlayersTicker = time.NewTicker(clearPlayerTimeInterval)
 
 	// make room players map
 	room.players = make(map[string]*Player, room.options.PlayerSize)
+}
 
-	// make room data channel
-	room.dataChan = make(chan *RoomData, 1)
-
+// resetScene creates the border and the food of the room options.
+func (room *Room) resetScene() {
+	room.border = NewRecBorder(
+		room.options.BorderWidth, room.options.BorderHeight,
+		"",
+	)
+	room.food = NewFoodManager(room.posLimit, room.options.FoodSpawn, room.options.GoldenFoodPercent)
+	room.updateFood()
 }
 
+// Run runs the room until the context is done or the room is retired and
+// empty.
 func (room *Room) Run(ctx context.Context) {
 	room.Init()
+	defer close(room.done)
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		room.writer.Run(ctx)
+		wg.Done()
+	}()
 	for {
 		select {
 		case <-ctx.Done():
@@ -77,27 +207,195 @@ func (room *Room) Run(ctx context.Context) {
 			room.handleAutoTicker()
 		case <-room.clearPlayersTicker.C:
 			room.clearDisconnectedPlayers()
+		case f := <-room.execChan:
+			f()
 		}
+		if room.checkIdle() {
+			logger(LogRoom).Info("room stopped", "room", room.id)
+			return
+		}
+	}
+}
+
+// Exec runs the function in the room goroutine, it reports false if the
+// room is not running anymore.
+func (room *Room) Exec(f func()) bool {
+	select {
+	case room.execChan <- f:
+		return true
+	case <-room.done:
+		return false
 	}
 }
 
+// Call runs the function in the room goroutine and waits for it, it
+// reports false if the room is not running anymore.
+func (room *Room) Call(f func()) bool {
+	done := make(chan struct{})
+	if !room.Exec(func() {
+		f()
+		close(done)
+	}) {
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-room.done:
+		return false
+	}
+}
+
+// Reload changes the options of the room, the options are applied once
+// the room is empty so that the running games are not disturbed.
+func (room *Room) Reload(options *RoomOptions) bool {
+	options = options.clone()
+	return room.Exec(func() {
+		room.pending = options
+	})
+}
+
+// Retire closes the room to the new players, the room stops once the
+// players left.
+func (room *Room) Retire() bool {
+	atomic.StoreInt32(&room.retired, 1)
+	// wake up the room to check whether it is empty
+	return room.Exec(func() {})
+}
+
+// IsRetired reports whether the room is closed to the new players, it is
+// safe to call it from other goroutines.
+func (room *Room) IsRetired() bool {
+	return atomic.LoadInt32(&room.retired) == 1
+}
+
+// Done returns a channel closed once the room stops.
+func (room *Room) Done() <-chan struct{} {
+	return room.done
+}
+
+// checkIdle applies the pending options if the room is empty, it reports
+// whether the empty room should stop since it is retired, or since it is
+// empty for the idle retire.
+func (room *Room) checkIdle() bool {
+	if len(room.players) > 0 {
+		room.emptySince = time.Time{}
+		return false
+	}
+	if room.IsRetired() {
+		return true
+	}
+	if room.idleRetire > 0 {
+		now := time.Now()
+		if room.emptySince.IsZero() {
+			room.emptySince = now
+		} else if now.Sub(room.emptySince) >= room.idleRetire {
+			logger(LogRoom).Info("idle room retired", "room", room.id)
+			atomic.StoreInt32(&room.retired, 1)
+			return true
+		}
+	}
+	if room.pending != nil {
+		room.applyOptions(room.pending)
+		room.pending = nil
+		logger(LogRoom).Info("room options applied", "room", room.id)
+	}
+	return false
+}
+
+func (room *Room) applyOptions(options *RoomOptions) {
+	room.optionsMu.Lock()
+	room.options = *options
+	room.optionsMu.Unlock()
+	room.posLimit = options.getPosLimit()
+	room.writer.SetBandwidth(options.MaxClientBandwidth)
+	room.resetScene()
+	room.autoticker.Reset(room.getTickInterval())
+}
+
 type RoomData struct {
 	Sender     *net.UDPAddr
 	ClientData *ClientData
+	Secure     bool
+}
+
+// HandleData passes the data to the room without blocking, it reports
+// false if the data is dropped since the room is busy.
+func (room *Room) HandleData(data *RoomData) bool {
+	select {
+	case room.dataChan <- data:
+		return true
+	default:
+		return false
+	}
 }
 
-func (room *Room) HandleData(data *RoomData) {
-	room.dataChan <- data
+// GetLimitedCMDs returns the number of the commands dropped since their
+// players send too fast, it is safe to call it from other goroutines.
+func (room *Room) GetLimitedCMDs() uint64 {
+	return atomic.LoadUint64(&room.limitedCMDs)
 }
 
+// handleData handles the data of the joined players, the other senders
+// have to join the room at first. The rooms require secure only accept
+// the data opened with the session of the sender.
 func (room *Room) handleData(data *RoomData) {
+	if room.options.RequireSecure && !data.Secure {
+		if data.ClientData.CMD == CMDJoin {
+			room.sendError(data.Sender, data.ClientData.MaxDatagramSize, "room requires secure mode")
+		}
+		return
+	}
+	if data.ClientData.CMD == CMDJoin {
+		room.handleJoin(data)
+		return
+	}
+	player := room.players[data.Sender.String()]
+	if player == nil {
+		return
+	}
+	if !player.AllowCMD(time.Now()) {
+		atomic.AddUint64(&room.limitedCMDs, 1)
+		return
+	}
+	logger(LogRoom).Debug(
+		"command", "room", room.id, "player", player.GetID(),
+		"cmd", data.ClientData.CMD,
+	)
+	player.UpdateLastRecv()
+	room.handlePlayerCMD(data.ClientData, player)
+	room.dirty = true
+}
+
+// handleJoin adds the sender to the room if it is not in yet, the join
+// is acknowledged either way so that a reconnecting client resumes.
+func (room *Room) handleJoin(data *RoomData) {
+	cliData := data.ClientData
+	if !room.options.CheckSecret(cliData.Password, cliData.InviteCode) {
+		logger(LogRoom).Warn(
+			"join denied", "room", room.id, "player", data.Sender.String(),
+		)
+		room.sendError(data.Sender, cliData.MaxDatagramSize, "wrong password or invite code")
+		return
+	}
 	player, err := room.getPlayer(data.Sender)
-	if err == nil {
-		fmt.Printf("[R] %s %s\n", player.GetID(), data.ClientData.CMD)
-		player.UpdateLastRecv()
-		room.handlePlayerCMD(data.ClientData.CMD, player)
-		room.sendAllPlayersData()
+	if err != nil {
+		room.sendError(data.Sender, cliData.MaxDatagramSize, err.Error())
+		return
 	}
+	logger(LogRoom).Info("player joined", "room", room.id, "player", player.GetID())
+	player.UpdateLastRecv()
+	player.SetDatagramSize(data.ClientData.MaxDatagramSize)
+	room.sendPlayerData(player, &ServerData{
+		Join: &JoinData{
+			PlayerID:     player.GetID(),
+			RoomID:       room.id,
+			Private:      room.options.IsPrivate(),
+			DatagramSize: player.GetDatagramSize(),
+			EventSeq:     player.GetEventAck(),
+		},
+	})
+	room.dirty = true
 }
 
 func (room *Room) clearDisconnectedPlayers() {
@@ -105,33 +403,64 @@ func (room *Room) clearDisconnectedPlayers() {
 	for playerID, player := range room.players {
 		lastRecv := player.GetLastRecv()
 		if lastRecv.Add(clearPlayerTimeInterval).Before(now) {
-			fmt.Printf("[C] %s %s\n", playerID, lastRecv.Format("15:03:04"))
-			delete(room.players, playerID)
+			logger(LogRoom).Info(
+				"player timed out", "room", room.id, "player", playerID,
+				"last_recv", lastRecv,
+			)
+			room.removePlayer(player)
 		}
 	}
 }
 
+// handleAutoTicker moves the players and sends the snapshots once per
+// tick, the changes made by the commands within the tick are coalesced.
 func (room *Room) handleAutoTicker() {
-	room.playersAutoMove()
-	room.sendAllPlayersData()
+	start := time.Now()
+	room.tick += 1
+	moved := !room.paused && room.playersAutoMove()
+	if moved || room.dirty {
+		room.sendAllPlayersData()
+		room.dirty = false
+	}
+	atomic.AddUint64(&room.counters.ticks, 1)
+	atomic.AddUint64(&room.counters.tickNanos, uint64(time.Since(start)))
 }
 
-func (room *Room) handlePlayerCMD(cmd CMD, player *Player) {
-	switch cmd {
+// getTickInterval returns the interval of the server tick, the snakes
+// move at most one step per tick.
+func (room *Room) getTickInterval() time.Duration {
+	ms := room.options.TickIntervalMS
+	if ms <= 0 || ms > room.options.AutoMoveIntervalMS {
+		ms = room.options.AutoMoveIntervalMS
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (room *Room) handlePlayerCMD(cliData *ClientData, player *Player) {
+	switch cliData.CMD {
+	case CMDPing:
+		room.playerPing(player, cliData)
 	case CMDPause:
 		room.playerPause(player)
 	case CMDReplay:
 		room.playerReplay(player)
 	case CMDQuit:
 		room.playerQuit(player)
+	case CMDBoost:
+		room.playerBoost(player)
+	case CMDChat:
+		room.playerChat(player, cliData.Text)
+	case CMDEventAck:
+		room.playerAckEvents(player, cliData.Seq)
 	default:
-		room.handlePlayerMovCMD(player, cmd)
+		room.handlePlayerMovCMD(player, cliData.CMD, cliData.Seq)
 	}
 }
 
-func (room *Room) handlePlayerMovCMD(player *Player, cmd CMD) {
-	if dir, ok := GetCMDDir(cmd); ok {
-		room.playerMove(player, dir, false)
+func (room *Room) handlePlayerMovCMD(player *Player, cmd CMD, seq uint32) {
+	if dir, ok := GetCMDDir(cmd); ok && !player.GetOver() {
+		player.UnPause()
+		player.QueueInput(dir, seq)
 	}
 }
 
@@ -141,31 +470,87 @@ func (room *Room) getPlayer(addr *net.UDPAddr) (player *Player, err error) {
 	if player != nil {
 		return
 	}
-	if len(room.players) > room.options.PlayerSize {
-		err = errors.New("players are too more")
+	if room.IsRetired() {
+		err = errRoomRetired
 		return
 	}
-	player, err = NewPlayer(addr, playerID, room.posLimit)
+	if len(room.players) >= room.options.PlayerSize {
+		err = errRoomFull
+		return
+	}
+	snake, err := room.spawnSnake(nil)
 	if err != nil {
 		return
 	}
+	player = NewPlayer(addr, playerID, snake)
+	player.AckEvents(room.eventSeq)
+	room.protectPlayer(player)
 	room.players[playerID] = player
+	atomic.StoreInt32(&room.playerNum, int32(len(room.players)))
+	room.addEvent(&GameEvent{Kind: EventJoin, PlayerID: playerID})
 	return
 }
 
+// spawnSnake creates a snake at a free position facing a clear runway,
+// the snake of the excepted player is not considered as occupied.
+func (room *Room) spawnSnake(except *Player) (*Snake, error) {
+	occupied := Takers{room.border, room.food}
+	for _, player := range room.players {
+		if player != except {
+			occupied = append(occupied, player.snake)
+		}
+	}
+	pos, dir, err := FindSpawn(
+		room.posLimit, room.options.SpawnPoints,
+		occupied, room.options.SpawnRunway,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return NewSnake(pos.X, pos.Y, dir), nil
+}
+
+func (room *Room) protectPlayer(player *Player) {
+	player.Protect(
+		time.Duration(room.options.SpawnProtectionMS) * time.Millisecond,
+	)
+}
+
 func (room *Room) sendAllPlayersData() {
-	wg := &sync.WaitGroup{}
 	for _, player := range room.players {
-		wg.Add(1)
-		go func(player *Player) {
-			defer wg.Done()
-			sceneData := room.getPlayerSceneData(player)
-			data := sceneData.Encode()
-			addr := player.GetAddr()
-			room.sendData(data, &addr)
-		}(player)
+		addr := player.GetAddr()
+		room.writer.SendSnapshot(
+			room.getPlayerSceneData(player), &addr, player.GetDatagramSize(),
+		)
+	}
+}
+
+func (room *Room) sendPlayerData(player *Player, data *ServerData) {
+	addr := player.GetAddr()
+	room.writer.Send(data.Encode(), &addr, player.GetDatagramSize())
+}
+
+func (room *Room) removePlayer(player *Player) {
+	addr := player.GetAddr()
+	room.writer.Forget(&addr)
+	delete(room.players, player.GetID())
+	atomic.StoreInt32(&room.playerNum, int32(len(room.players)))
+	room.addEvent(&GameEvent{Kind: EventLeave, PlayerID: player.GetID()})
+}
+
+func (room *Room) sendNotice(player *Player, message string) {
+	room.sendPlayerData(player, &ServerData{
+		Notice: &NoticeData{Message: message},
+	})
+}
+
+// sendError sends the error at the datagram size negotiated with the
+// sender, which may not be a player.
+func (room *Room) sendError(addr *net.UDPAddr, datagramSize int, message string) {
+	data := &ServerData{
+		Error: &ErrorData{Message: message},
 	}
-	wg.Wait()
+	room.writer.Send(data.Encode(), addr, datagramSize)
 }
 
 func (room *Room) getPlayerSceneData(player *Player) *SceneData {
@@ -175,16 +560,20 @@ func (room *Room) getPlayerSceneData(player *Player) *SceneData {
 		PlayerID:     player.GetID(),
 		BorderWidth:  w,
 		BorderHeight: h,
-		PlayerSnake:  NewCompressLayer(w, h),
-		Snakes:       NewCompressLayer(w, h),
+		Snakes:       make([]*SnakeData, 0, len(room.players)),
 		Food:         NewCompressLayer(w, h),
 		PlayerStats:  make(PlayerStats, 0),
+		Tick:         room.tick,
+		InputAck:     player.GetInputAck(),
+		Events:       room.getPlayerEvents(player),
+		ServerTime:   time.Now().UnixNano(),
 	}
-	sceneData.PlayerSnake.AddPositions(player.GetSnakeTakes())
 	sceneData.Food.AddPositions(room.food.GetTakes())
+	sceneData.GoldenFood = room.food.GetGoldenPositions()
 	for _, rplayer := range room.players {
-		sceneData.Snakes.AddPositions(
-			rplayer.GetSnakeTakes(),
+		sceneData.Snakes = append(
+			sceneData.Snakes,
+			rplayer.GetSnakeData(),
 		)
 		sceneData.PlayerStats = append(
 			sceneData.PlayerStats,
@@ -194,41 +583,127 @@ func (room *Room) getPlayerSceneData(player *Player) *SceneData {
 	return sceneData
 }
 
-func (room *Room) playerMove(player *Player, dir Direction, oeated bool) (ieated bool) {
+func (room *Room) getFoodNum() int {
+	return room.options.FoodNum + room.options.FoodPerPlayer*len(room.players)
+}
+
+// updateFood refills the eaten food items on the cells which are not taken
+// by the border or any snake.
+func (room *Room) updateFood() {
+	occupied := Takers{room.border}
+	heads := make([]Position, 0, len(room.players))
+	for _, player := range room.players {
+		occupied = append(occupied, player.snake)
+		heads = append(heads, player.GetSnakeHeadPos())
+	}
+	room.food.Update(room.getFoodNum(), occupied, heads)
+}
+
+// getMoveIntent returns the intended move of the player, nil is returned
+// if the player can not move to the direction.
+func (room *Room) getMoveIntent(player *Player, dir Direction) *moveIntent {
 	if player.GetOver() {
-		return
+		return nil
 	}
-	player.UnPause()
 	nextHeadPos := player.GetSnakeNextHeadPos(dir)
 	if nextHeadPos == nil {
-		return
+		return nil
 	}
-	if room.border.IsTaken(*nextHeadPos) {
-		player.Over()
-		return
+	return &moveIntent{
+		player: player,
+		dir:    dir,
+		next:   *nextHeadPos,
+		grows:  room.food.IsTaken(*nextHeadPos),
 	}
-	if player.IsSnakeTaken(*nextHeadPos) &&
-		*nextHeadPos != player.GetSnakeTailPos() {
-		player.Over()
+}
+
+// playersMove moves the players of the intents at the same time, then
+// lets the moved players eat.
+func (room *Room) playersMove(intents []*moveIntent) {
+	if len(intents) == 0 {
 		return
 	}
-	for opid, oplayer := range room.players {
-		if opid == player.GetID() {
+	players := make([]*Player, 0, len(room.players))
+	for _, player := range room.players {
+		players = append(players, player)
+	}
+	result := resolveMoves(
+		intents, players, room.border, room.options.CollisionRule,
+	)
+	moved := make([]*Player, 0, len(intents))
+	for _, in := range intents {
+		if death := result.deaths[in.player]; death != nil {
+			room.playerDie(in.player, death)
 			continue
 		}
-		if oplayer.IsSnakeTaken(*nextHeadPos) {
-			player.Over()
-			return
+		if result.stalled[in.player] {
+			continue
 		}
+		in.player.MoveSnake(in.dir)
+		moved = append(moved, in.player)
 	}
-	player.MoveSnake(dir)
-	if !oeated && room.food.IsTaken(*nextHeadPos) {
-		player.GrowSnake()
-		room.food.UpdatePos()
-		ieated = true
+	room.playersEat(moved)
+	for _, player := range moved {
+		player.ConsumeBoost(room.options.BoostCostSteps)
 	}
+	room.updateFood()
+}
 
-	return
+// playerDie ends the round of the player, the killer is credited with
+// the kill.
+func (room *Room) playerDie(player *Player, d *death) {
+	killerID := ""
+	if d.killer != nil && d.killer != player {
+		killerID = d.killer.GetID()
+		d.killer.AddKill()
+		atomic.AddUint64(&room.counters.kills, 1)
+	}
+	atomic.AddUint64(room.counters.deaths[d.cause], 1)
+	player.Die(d.cause, killerID)
+	room.sendPlayerData(player, &ServerData{Death: player.GetDeathData()})
+	logger(LogGame).Info(
+		"snake died", "room", room.id, "player", player.GetID(),
+		"cause", d.cause, "killer", killerID, "score", player.GetScore(),
+	)
+	room.addEvent(&GameEvent{
+		Kind:     EventDeath,
+		PlayerID: player.GetID(),
+		OtherID:  killerID,
+		Cause:    d.cause,
+		Score:    int(player.GetScore()),
+	})
+}
+
+// playersEat lets the moved players eat the food under their heads, when
+// several heads reach the same item in one tick, it goes to the shortest
+// snake, then to the earliest joined player.
+func (room *Room) playersEat(moved []*Player) {
+	contenders := make(map[Position][]*Player)
+	for _, player := range moved {
+		headPos := player.GetSnakeHeadPos()
+		if room.food.IsTaken(headPos) {
+			contenders[headPos] = append(contenders[headPos], player)
+		}
+	}
+	for pos, players := range contenders {
+		sort.Slice(players, func(i, j int) bool {
+			return players[i].Before(players[j])
+		})
+		food := room.food.Eat(pos)
+		if food == nil {
+			continue
+		}
+		atomic.AddUint64(&room.counters.foodEaten, 1)
+		logger(LogGame).Debug(
+			"food eaten", "room", room.id, "player", players[0].GetID(),
+			"len", players[0].GetSnakeLen()+1, "golden", food.IsGolden(),
+		)
+		players[0].GrowSnake()
+		if food.IsGolden() {
+			players[0].SetSpeedEffect(goldenFoodSpeed, goldenFoodDuration)
+			room.addEvent(&GameEvent{Kind: EventPickup, PlayerID: players[0].GetID()})
+		}
+	}
 }
 
 func (room *Room) playerPause(player *Player) {
@@ -243,23 +718,57 @@ func (room *Room) playerPause(player *Player) {
 }
 
 func (room *Room) playerReplay(player *Player) {
+	if !player.GetOver() {
+		return
+	}
+	snake, err := room.spawnSnake(player)
+	if err != nil {
+		return
+	}
+	player.Reset(snake)
+	room.protectPlayer(player)
+	room.addEvent(&GameEvent{Kind: EventRound, PlayerID: player.GetID()})
+}
+
+// playerPing replies the ping of the player with a pong and measures the
+// latency of the player with the echoed server time.
+func (room *Room) playerPing(player *Player, cliData *ClientData) {
+	player.UpdateLatency(cliData.EchoServerTime, cliData.EchoDelay)
+	room.sendPlayerData(player, &ServerData{
+		Pong: &PongData{ClientTime: cliData.ClientTime},
+	})
+}
+
+func (room *Room) playerBoost(player *Player) {
 	if player.GetOver() {
-		player.Reset(room.posLimit)
+		return
 	}
+	player.ToggleBoost()
 }
 
 func (room *Room) playerQuit(player *Player) {
-	delete(room.players, player.GetID())
+	room.removePlayer(player)
 }
 
-func (room *Room) playersAutoMove() {
-	eated := false
+// playersAutoMove moves the players whose accumulated movement reaches a
+// step, it reports whether any player moved.
+func (room *Room) playersAutoMove() bool {
+	elapsed := room.getTickInterval()
+	interval := time.Duration(room.options.AutoMoveIntervalMS) * time.Millisecond
+	intents := make([]*moveIntent, 0, len(room.players))
 	for _, player := range room.players {
-		if player.GetPause() {
+		if player.GetPause() || player.GetOver() {
+			player.ResetAcc()
 			continue
 		}
-		eated = room.playerMove(
-			player, player.snake.GetDir(), eated,
-		)
+		if !player.Accelerate(elapsed, interval) {
+			continue
+		}
+		in := room.getMoveIntent(player, player.NextDir())
+		if in != nil {
+			intents = append(intents, in)
+		}
 	}
+	room.playersMove(intents)
+	return len(intents) > 0
 }
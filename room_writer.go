@@ -0,0 +1,161 @@
+package gosnake
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type sendFunc func(data []byte, addr *net.UDPAddr, datagramSize int)
+
+type outMessage struct {
+	data         []byte
+	addr         *net.UDPAddr
+	datagramSize int
+	// scene is encoded once the snapshot is sent, so that only the sent
+	// snapshots take a sequence number
+	scene *SceneData
+}
+
+// roomWriter is the only one who sends the data of a room. The snapshots
+// to the same client are coalesced so only the latest one is sent, and
+// the snapshots exceed the bandwidth of the client are dropped.
+type roomWriter struct {
+	send      sendFunc
+	bandwidth int
+	mu        sync.Mutex
+	messages  []*outMessage
+	snapshots map[string]*outMessage
+	buckets   map[string]*TokenBucket
+	seqs      map[string]uint64
+	notify    chan struct{}
+	dropped   uint64
+}
+
+func newRoomWriter(send sendFunc, bandwidth int) *roomWriter {
+	return &roomWriter{
+		send:      send,
+		bandwidth: bandwidth,
+		snapshots: make(map[string]*outMessage),
+		buckets:   make(map[string]*TokenBucket),
+		seqs:      make(map[string]uint64),
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+func (w *roomWriter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			// the queued messages such as the last errors are sent
+			w.flush()
+			return
+		case <-w.notify:
+			w.flush()
+		}
+	}
+}
+
+// Send queues the data which is always sent in order and never dropped,
+// such as the replies.
+func (w *roomWriter) Send(data []byte, addr *net.UDPAddr, datagramSize int) {
+	w.mu.Lock()
+	w.messages = append(w.messages, &outMessage{data: data, addr: addr, datagramSize: datagramSize})
+	w.mu.Unlock()
+	w.wakeup()
+}
+
+// SendSnapshot replaces the snapshot to the address which is not sent yet,
+// the scene is owned by the writer from now on.
+func (w *roomWriter) SendSnapshot(scene *SceneData, addr *net.UDPAddr, datagramSize int) {
+	w.mu.Lock()
+	w.snapshots[addr.String()] = &outMessage{addr: addr, datagramSize: datagramSize, scene: scene}
+	w.mu.Unlock()
+	w.wakeup()
+}
+
+// Forget drops the state of the address when the player leaves.
+func (w *roomWriter) Forget(addr *net.UDPAddr) {
+	w.mu.Lock()
+	delete(w.snapshots, addr.String())
+	delete(w.buckets, addr.String())
+	delete(w.seqs, addr.String())
+	w.mu.Unlock()
+}
+
+func (w *roomWriter) wakeup() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *roomWriter) flush() {
+	w.mu.Lock()
+	messages := w.messages
+	snapshots := w.snapshots
+	w.messages = nil
+	w.snapshots = make(map[string]*outMessage, len(snapshots))
+	w.mu.Unlock()
+
+	now := time.Now()
+	for _, msg := range messages {
+		w.send(msg.data, msg.addr, msg.datagramSize)
+	}
+	for _, msg := range snapshots {
+		w.sendSnapshot(msg, now)
+	}
+}
+
+// sendSnapshot encodes the snapshot with the next sequence number of the
+// client, the number is taken only if the snapshot is sent.
+func (w *roomWriter) sendSnapshot(msg *outMessage, now time.Time) {
+	key := msg.addr.String()
+	w.mu.Lock()
+	seq := w.seqs[key] + 1
+	w.mu.Unlock()
+	msg.scene.Seq = seq
+	msg.data = fitSceneEvents(msg.scene, msg.datagramSize)
+	if !w.allow(msg, now) {
+		atomic.AddUint64(&w.dropped, 1)
+		return
+	}
+	w.mu.Lock()
+	w.seqs[key] = seq
+	w.mu.Unlock()
+	w.send(msg.data, msg.addr, msg.datagramSize)
+}
+
+// GetDropped returns the number of the snapshots dropped since they
+// exceed the bandwidth.
+func (w *roomWriter) GetDropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// SetBandwidth changes the bandwidth of the clients, the buckets are
+// refilled with the new bandwidth.
+func (w *roomWriter) SetBandwidth(bandwidth int) {
+	w.mu.Lock()
+	w.bandwidth = bandwidth
+	w.buckets = make(map[string]*TokenBucket)
+	w.mu.Unlock()
+}
+
+// allow charges the bandwidth of the client for the snapshot.
+func (w *roomWriter) allow(msg *outMessage, now time.Time) bool {
+	key := msg.addr.String()
+	w.mu.Lock()
+	if w.bandwidth <= 0 {
+		w.mu.Unlock()
+		return true
+	}
+	bucket := w.buckets[key]
+	if bucket == nil {
+		bucket = NewTokenBucket(float64(w.bandwidth), float64(w.bandwidth))
+		w.buckets[key] = bucket
+	}
+	w.mu.Unlock()
+	return bucket.Allow(now, float64(len(msg.data)))
+}
@@ -0,0 +1,64 @@
+package gosnake
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestRoomWriter(t *testing.T) {
+	a := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	b := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2}
+	scene := func(id string) *SceneData {
+		return &SceneData{PlayerID: id, Food: &CompressLayer{}}
+	}
+	for _, c := range []struct {
+		name      string
+		bandwidth int
+		queue     func(w *roomWriter)
+		want      []string
+		dropped   uint64
+	}{
+		{"coalesced", 0, func(w *roomWriter) {
+			w.SendSnapshot(scene("s1"), a, minDatagramSize)
+			w.SendSnapshot(scene("s2"), a, minDatagramSize)
+			w.Send([]byte("r1"), a, minDatagramSize)
+		}, []string{"r1 " + a.String(), "s2#1 " + a.String()}, 0},
+		{"replies in order", 0, func(w *roomWriter) {
+			w.Send([]byte("r1"), a, minDatagramSize)
+			w.Send([]byte("r2"), b, minDatagramSize)
+			w.Send([]byte("r3"), a, minDatagramSize)
+		}, []string{"r1 " + a.String(), "r2 " + b.String(), "r3 " + a.String()}, 0},
+		{"forgotten", 0, func(w *roomWriter) {
+			w.SendSnapshot(scene("s1"), a, minDatagramSize)
+			w.Forget(a)
+		}, nil, 0},
+		// the dropped snapshot takes no sequence number
+		{"over bandwidth", 20, func(w *roomWriter) {
+			w.SendSnapshot(scene("s1"), a, minDatagramSize)
+			w.flush()
+			w.SendSnapshot(scene("s2-too-large-for-the-bandwidth"), a, minDatagramSize)
+			w.SendSnapshot(scene("s3"), b, minDatagramSize)
+			w.flush()
+			w.SetBandwidth(0)
+			w.SendSnapshot(scene("s4"), a, minDatagramSize)
+		}, []string{"s1#1 " + a.String(), "s3#1 " + b.String(), "s4#2 " + a.String()}, 1},
+	} {
+		var sent []string
+		w := newRoomWriter(func(data []byte, addr *net.UDPAddr, datagramSize int) {
+			if serverData, err := DecodeServerData(data); err == nil && serverData.Scene != nil {
+				data = []byte(fmt.Sprintf("%s#%d", serverData.Scene.PlayerID, serverData.Scene.Seq))
+			}
+			sent = append(sent, string(data)+" "+addr.String())
+		}, c.bandwidth)
+		c.queue(w)
+		w.flush()
+		if strings.Join(sent, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: got sent %q, want %q", c.name, sent, c.want)
+		}
+		if w.GetDropped() != c.dropped {
+			t.Errorf("%s: got %d dropped, want %d", c.name, w.GetDropped(), c.dropped)
+		}
+	}
+}
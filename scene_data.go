@@ -1,35 +1,134 @@
 package gosnake
 
-import (
-	"bytes"
-	"encoding/gob"
-)
-
 type SceneData struct {
 	PlayerID     string
 	BorderWidth  int
 	BorderHeight int
-	PlayerSnake  *CompressLayer
-	Snakes       *CompressLayer
+	Snakes       []*SnakeData
 	Food         *CompressLayer
+	GoldenFood   []Position
 	PlayerStats  PlayerStats
+	Tick         uint64
+	InputAck     uint32
+	Seq          uint64
+	ServerTime   int64
+	// Events are the game events not acked by the player yet.
+	Events []*GameEvent
+}
+
+// getScenePayloadSize returns the size of the snapshot which fits in one
+// datagram of the size, the datagram carries the header of the child and
+// the seal of the secure session as well.
+func getScenePayloadSize(datagramSize int) int {
+	if datagramSize <= 0 || datagramSize > splitChildPackageSize {
+		datagramSize = splitChildPackageSize
+	}
+	return datagramSize - childHeaderSize - minSealedSize
+}
+
+// Bounds of the encoded fields used to estimate the largest snapshot, the
+// id of a player is its address.
+const (
+	maxPlayerIDSize = 64
+	maxStatSize     = maxPlayerIDSize + 16
+	maxSnakeSize    = maxPlayerIDSize + 16
+	maxEventSize    = 3*maxPlayerIDSize + 32
+	maxPositionSize = 6
+)
+
+// getMaxSnapshotSize returns the size of the largest snapshot of the room,
+// where the snakes fill the board and turn at every node.
+func (options *RoomOptions) getMaxSnapshotSize() int {
+	side := options.BorderWidth
+	if options.BorderHeight > side {
+		side = options.BorderHeight
+	}
+	limit := options.getPosLimit()
+	cells := (limit.MaxX - limit.MinX + 1) * (limit.MaxY - limit.MinY + 1)
+	foodNum := options.FoodNum + options.FoodPerPlayer*options.PlayerSize
+	if foodNum > cells {
+		foodNum = cells
+	}
+	size := 64 + (side+7)/8*side + foodNum*maxPositionSize
+	size += options.PlayerSize * (maxStatSize + maxSnakeSize)
+	// a run of the path takes its direction and its length
+	size += 2 * cells
+	return size + maxSceneEvents*maxEventSize
 }
 
-func (scd *SceneData) Encode() []byte {
-	var buf bytes.Buffer
-	err := gob.NewEncoder(&buf).Encode(scd)
-	if err != nil {
-		panic(err)
+// GetPlayerSnake returns the snake of the player, nil is returned if
+// it is not in the snapshot.
+func (scd *SceneData) GetPlayerSnake() *SnakeData {
+	for _, sd := range scd.Snakes {
+		if sd.ID == scd.PlayerID {
+			return sd
+		}
 	}
-	return buf.Bytes()
+	return nil
 }
 
-func DecodeSceneData(data []byte) *SceneData {
-	scd := &SceneData{}
-	buf := bytes.NewBuffer(data)
-	err := gob.NewDecoder(buf).Decode(scd)
-	if err != nil {
-		panic(err)
-	}
-	return scd
+func (scd *SceneData) encode(w *wireWriter) {
+	w.String(scd.PlayerID)
+	w.Uint(uint64(scd.BorderWidth))
+	w.Uint(uint64(scd.BorderHeight))
+	w.Uint(uint64(len(scd.Snakes)))
+	for _, sd := range scd.Snakes {
+		sd.encode(w)
+	}
+	scd.Food.encode(w)
+	w.Uint(uint64(len(scd.GoldenFood)))
+	for _, pos := range scd.GoldenFood {
+		w.Position(pos)
+	}
+	w.Uint(uint64(len(scd.PlayerStats)))
+	for _, stat := range scd.PlayerStats {
+		stat.encode(w)
+	}
+	w.Uint(scd.Tick)
+	w.Uint(uint64(scd.InputAck))
+	w.Uint(scd.Seq)
+	w.Int(scd.ServerTime)
+	w.Uint(uint64(len(scd.Events)))
+	for _, event := range scd.Events {
+		event.encode(w)
+	}
+}
+
+func (scd *SceneData) decode(r *wireReader) {
+	scd.PlayerID = r.String()
+	scd.BorderWidth = int(r.Uint())
+	scd.BorderHeight = int(r.Uint())
+	if n := r.Len(); n > 0 {
+		scd.Snakes = make([]*SnakeData, n)
+		for i := range scd.Snakes {
+			scd.Snakes[i] = &SnakeData{}
+			scd.Snakes[i].decode(r)
+		}
+	}
+	scd.Food = &CompressLayer{}
+	scd.Food.decode(r)
+	if n := r.Len(); n > 0 {
+		scd.GoldenFood = make([]Position, n)
+		for i := range scd.GoldenFood {
+			scd.GoldenFood[i] = r.Position()
+		}
+	}
+	if n := r.Len(); n > 0 {
+		scd.PlayerStats = make(PlayerStats, n)
+		for i := range scd.PlayerStats {
+			scd.PlayerStats[i] = &PlayerStat{}
+			scd.PlayerStats[i].decode(r)
+		}
+	}
+	scd.Tick = r.Uint()
+	scd.InputAck = uint32(r.Uint())
+	scd.Seq = r.Uint()
+	scd.ServerTime = r.Int()
+	if n := r.Len(); n > 0 {
+		scd.Events = make([]*GameEvent, n)
+		for i := range scd.Events {
+			scd.Events[i] = &GameEvent{}
+			scd.Events[i].decode(r)
+		}
+	}
 }
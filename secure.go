@@ -0,0 +1,242 @@
+package gosnake
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A sealed packet starts with a zero byte, which never starts a gob
+// message, followed by the counter and the ciphertext.
+const (
+	sealedPacketFlag   byte = 0
+	sealedHeaderSize        = 9
+	sealOverhead            = 16
+	minSealedSize           = sealedHeaderSize + sealOverhead
+	replayWindow            = 64
+	secureSessionIdle       = time.Minute
+	clientToServerInfo      = "gosnake client to server"
+	serverToClientInfo      = "gosnake server to client"
+	handshakeSignInfo       = "gosnake handshake"
+)
+
+var (
+	errNotSealed = errors.New("packet is not sealed")
+	errReplayed  = errors.New("packet is replayed")
+	errNoSession = errors.New("packet has no session")
+	errNotOpened = errors.New("packet can not be opened")
+)
+
+// rehandshakeMarker is replied in plain to a sealed packet without a
+// session, so that the client handshakes again. With the header of the
+// child it is shorter than any sealed packet, a spoofed packet is never
+// amplified by the reply.
+var rehandshakeMarker = []byte{0xff, 'r', 'h'}
+
+func IsSealed(packet []byte) bool {
+	return len(packet) > 0 && packet[0] == sealedPacketFlag
+}
+
+func IsRehandshake(data []byte) bool {
+	return bytes.Equal(data, rehandshakeMarker)
+}
+
+func NewKeyPair() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// LoadServerKey reads the key the server signs the handshakes with, the
+// key is created and saved if the file does not exist. The key of an
+// empty path only lasts for the run.
+func LoadServerKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		seed := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+		if err := os.WriteFile(path, []byte(seed), 0o600); err != nil {
+			return nil, fmt.Errorf("key file %s: %w", path, err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("key file %s: invalid key", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// EncodeServerKey returns the public key of the server pinned by the
+// clients.
+func EncodeServerKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// ParseServerKey parses the public key of the server pinned by the client.
+func ParseServerKey(s string) (ed25519.PublicKey, error) {
+	if s == "" {
+		return nil, errors.New("server key is missing, it is logged by the server on start")
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("server key %q is invalid", s)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// signHandshake signs both public keys of the exchange, so that the client
+// knows the reply comes from the server it pins and is for its own key.
+func signHandshake(key ed25519.PrivateKey, clientPub, serverPub []byte) []byte {
+	return ed25519.Sign(key, handshakeMessage(clientPub, serverPub))
+}
+
+func verifyHandshake(key ed25519.PublicKey, clientPub, serverPub, sig []byte) bool {
+	return ed25519.Verify(key, handshakeMessage(clientPub, serverPub), sig)
+}
+
+func handshakeMessage(clientPub, serverPub []byte) []byte {
+	message := append([]byte(handshakeSignInfo), clientPub...)
+	return append(message, serverPub...)
+}
+
+// SecureSession encrypts and authenticates the packets of one peer with
+// the keys derived from an X25519 key exchange, the replayed packets are
+// rejected with a sliding window.
+type SecureSession struct {
+	mu          sync.Mutex
+	send        cipher.AEAD
+	recv        cipher.AEAD
+	sendCounter uint64
+	recvMax     uint64
+	recvMask    uint64
+	lastSeen    time.Time
+}
+
+// NewSecureSession creates the session with the local private key and the
+// public key of the peer, the server and the client use different keys to
+// send.
+func NewSecureSession(priv *ecdh.PrivateKey, peerPub []byte, isServer bool) (*SecureSession, error) {
+	pub, err := ecdh.X25519().NewPublicKey(peerPub)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	clientPub, serverPub := priv.PublicKey().Bytes(), peerPub
+	if isServer {
+		clientPub, serverPub = serverPub, clientPub
+	}
+	c2s, err := newSessionAEAD(clientToServerInfo, shared, clientPub, serverPub)
+	if err != nil {
+		return nil, err
+	}
+	s2c, err := newSessionAEAD(serverToClientInfo, shared, clientPub, serverPub)
+	if err != nil {
+		return nil, err
+	}
+	ss := &SecureSession{send: c2s, recv: s2c, lastSeen: time.Now()}
+	if isServer {
+		ss.send, ss.recv = s2c, c2s
+	}
+	return ss, nil
+}
+
+func newSessionAEAD(info string, shared, clientPub, serverPub []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte(info))
+	h.Write(shared)
+	h.Write(clientPub)
+	h.Write(serverPub)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (ss *SecureSession) Seal(data []byte) []byte {
+	ss.mu.Lock()
+	ss.sendCounter += 1
+	counter := ss.sendCounter
+	ss.mu.Unlock()
+	packet := make([]byte, sealedHeaderSize, sealedHeaderSize+len(data)+ss.send.Overhead())
+	packet[0] = sealedPacketFlag
+	binary.BigEndian.PutUint64(packet[1:sealedHeaderSize], counter)
+	return ss.send.Seal(packet, makeNonce(counter), data, packet[:sealedHeaderSize])
+}
+
+func (ss *SecureSession) Open(packet []byte) ([]byte, error) {
+	if !IsSealed(packet) || len(packet) < sealedHeaderSize {
+		return nil, errNotSealed
+	}
+	counter := binary.BigEndian.Uint64(packet[1:sealedHeaderSize])
+	data, err := ss.recv.Open(
+		nil, makeNonce(counter), packet[sealedHeaderSize:], packet[:sealedHeaderSize],
+	)
+	if err != nil {
+		return nil, err
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if !ss.accept(counter) {
+		return nil, errReplayed
+	}
+	ss.lastSeen = time.Now()
+	return data, nil
+}
+
+// accept checks the counter against the window of the received counters.
+func (ss *SecureSession) accept(counter uint64) bool {
+	if counter > ss.recvMax {
+		shift := counter - ss.recvMax
+		if shift >= replayWindow {
+			ss.recvMask = 0
+		} else {
+			ss.recvMask <<= shift
+		}
+		ss.recvMask |= 1
+		ss.recvMax = counter
+		return true
+	}
+	offset := ss.recvMax - counter
+	if offset >= replayWindow || ss.recvMask&(1<<offset) != 0 {
+		return false
+	}
+	ss.recvMask |= 1 << offset
+	return true
+}
+
+func (ss *SecureSession) IsIdle(now time.Time) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return now.Sub(ss.lastSeen) > secureSessionIdle
+}
+
+func makeNonce(counter uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
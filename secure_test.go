@@ -0,0 +1,236 @@
+package gosnake
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSecureSession(t *testing.T) {
+	clientKey, _ := NewKeyPair()
+	serverKey, _ := NewKeyPair()
+	client, err := NewSecureSession(clientKey, serverKey.PublicKey().Bytes(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewSecureSession(serverKey, clientKey.PublicKey().Bytes(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("MOVE_UP")
+	p1, p2 := client.Seal(msg), client.Seal(msg)
+	if !IsSealed(p1) {
+		t.Errorf("sealed packet is not marked")
+	}
+	if data, err := server.Open(p2); err != nil || !bytes.Equal(data, msg) {
+		t.Errorf("got %q %v, want %q", data, err, msg)
+	}
+	if _, err := server.Open(p1); err != nil {
+		t.Errorf("reordered packet is rejected: %v", err)
+	}
+	if _, err := server.Open(p1); err != errReplayed {
+		t.Errorf("replayed packet got %v", err)
+	}
+	p3 := client.Seal(msg)
+	p3[len(p3)-1] ^= 1
+	if _, err := server.Open(p3); err == nil {
+		t.Errorf("tampered packet is accepted")
+	}
+	if _, err := client.Open(client.Seal(msg)); err == nil {
+		t.Errorf("packet is accepted in the reverse direction")
+	}
+	if data, err := client.Open(server.Seal(msg)); err != nil || !bytes.Equal(data, msg) {
+		t.Errorf("got %q %v, want %q", data, err, msg)
+	}
+}
+
+func TestHandshakeHijack(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	server := NewServer(DefaultServerOptions)
+	server.key, _ = LoadServerKey("")
+	player := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	handshake := func(secure bool) *SecureSession {
+		key, _ := NewKeyPair()
+		server.handleHandshake(&ClientData{
+			CMD:       CMDHandshake,
+			PublicKey: key.PublicKey().Bytes(),
+		}, secure, player, conn)
+		return server.getSession(player)
+	}
+
+	session := handshake(false)
+	if session == nil {
+		t.Fatal("session is not created")
+	}
+	if handshake(false) != session {
+		t.Error("live session is replaced by a plain handshake")
+	}
+	rekeyed := handshake(true)
+	if rekeyed == session {
+		t.Error("live session is not replaced by a sealed handshake")
+	}
+	rekeyed.lastSeen = time.Now().Add(-2 * secureSessionIdle)
+	if handshake(false) == rekeyed {
+		t.Error("idle session is not replaced by a plain handshake")
+	}
+}
+
+func TestRehandshake(t *testing.T) {
+	server := NewServer(DefaultServerOptions)
+	player := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	clientKey, _ := NewKeyPair()
+	serverKey, _ := NewKeyPair()
+	session, err := NewSecureSession(clientKey, serverKey.PublicKey().Bytes(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := server.openPacket(session.Seal([]byte("ping")), player); err != errNoSession {
+		t.Errorf("sealed packet without a session got %v", err)
+	}
+	// the reply is never larger than the packet
+	if _, _, err := server.openPacket([]byte{sealedPacketFlag}, player); err != errNotSealed {
+		t.Errorf("short sealed packet without a session got %v", err)
+	}
+	if size := childHeaderSize + len(rehandshakeMarker); size > minSealedSize {
+		t.Errorf("got rehandshake reply of %d bytes over the sealed packet of %d", size, minSealedSize)
+	}
+	now := time.Now()
+	for i := 0; i < addrRehandshakeBurst; i++ {
+		if !server.guard.AllowRehandshake(player, now) {
+			t.Fatalf("rehandshake %d is refused within the burst", i)
+		}
+	}
+	if server.guard.AllowRehandshake(player, now) {
+		t.Error("rehandshake over the burst is allowed")
+	}
+
+	client := &Client{
+		options: &ClientOptions{Secure: true},
+		network: &Network{Send: make(chan []byte, 4)},
+		session: session,
+	}
+	rehandshake := append([]byte(nil), rehandshakeMarker...)
+	for i, want := range []int{1, 0} {
+		if err := client.update(rehandshake); err != nil {
+			t.Fatalf("rehandshake %d got %v", i, err)
+		}
+		if got := len(client.network.Send); got != want {
+			t.Errorf("rehandshake %d sent %d handshakes, want %d", i, got, want)
+		}
+		for len(client.network.Send) > 0 {
+			if packet := <-client.network.Send; IsSealed(packet) {
+				t.Error("handshake is sealed with the lost session")
+			}
+		}
+	}
+	if client.session != session || client.handshakeKey == nil {
+		t.Error("session is dropped before the handshake is replied")
+	}
+	client.handshakeAt = time.Now().Add(-rehandshakeInterval)
+	client.update(rehandshake)
+	if len(client.network.Send) != 1 {
+		t.Error("handshake is not resent after the interval")
+	}
+
+	if _, err := client.decodeServerData((&ServerData{
+		Error: &ErrorData{Message: "spoofed"},
+	}).Encode()); err != errNotSealed {
+		t.Errorf("plain error got %v", err)
+	}
+}
+
+func TestServerKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.key")
+	key, err := LoadServerKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadServerKey(path)
+	if err != nil || !loaded.Equal(key) {
+		t.Errorf("got %v, want the key saved on the first load", err)
+	}
+	if pub, err := ParseServerKey(EncodeServerKey(key)); err != nil || !pub.Equal(key.Public()) {
+		t.Errorf("got %v, want the public key of the server", err)
+	}
+	if err := os.WriteFile(path, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadServerKey(path); err == nil {
+		t.Error("invalid key file is loaded")
+	}
+	for _, s := range []string{"", "AAAA", "not a key"} {
+		if _, err := ParseServerKey(s); err == nil {
+			t.Errorf("server key %q is parsed", s)
+		}
+	}
+}
+
+func TestHandshakeSignature(t *testing.T) {
+	serverKey, _ := LoadServerKey("")
+	otherKey, _ := LoadServerKey("")
+	otherClient, _ := NewKeyPair()
+	for _, c := range []struct {
+		name   string
+		signer func(clientPub, serverPub []byte) []byte
+		ok     bool
+	}{
+		{"signed by the server", func(clientPub, serverPub []byte) []byte {
+			return signHandshake(serverKey, clientPub, serverPub)
+		}, true},
+		{"signed by another key", func(clientPub, serverPub []byte) []byte {
+			return signHandshake(otherKey, clientPub, serverPub)
+		}, false},
+		{"signed for another client", func(clientPub, serverPub []byte) []byte {
+			return signHandshake(serverKey, otherClient.PublicKey().Bytes(), serverPub)
+		}, false},
+		{"not signed", func(clientPub, serverPub []byte) []byte {
+			return nil
+		}, false},
+	} {
+		client := &Client{
+			options:   &ClientOptions{Secure: true},
+			network:   &Network{Send: make(chan []byte, 4)},
+			serverKey: serverKey.Public().(ed25519.PublicKey),
+		}
+		client.sendHandshake(false)
+		<-client.network.Send
+		priv, _ := NewKeyPair()
+		serverPub := priv.PublicKey().Bytes()
+		client.handleHandshake(&HandshakeData{
+			PublicKey: serverPub,
+			Signature: c.signer(client.handshakeKey.PublicKey().Bytes(), serverPub),
+		})
+		if (client.session != nil) != c.ok {
+			t.Errorf("%s: got session %v, want %v", c.name, client.session != nil, c.ok)
+		}
+	}
+}
+
+func TestSpoofedSealedPacket(t *testing.T) {
+	server := NewServer(DefaultServerOptions)
+	player := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	clientKey, _ := NewKeyPair()
+	serverKey, _ := NewKeyPair()
+	client, _ := NewSecureSession(clientKey, serverKey.PublicKey().Bytes(), false)
+	session, _ := NewSecureSession(serverKey, clientKey.PublicKey().Bytes(), true)
+	server.sessions[player.String()] = session
+
+	// the spoofed packet is not opened, so it is not held against the player
+	spoofed := append([]byte{sealedPacketFlag}, make([]byte, 40)...)
+	if _, secure, err := server.openPacket(spoofed, player); err != errNotOpened || secure {
+		t.Errorf("spoofed packet got %v and secure %v", err, secure)
+	}
+	// the garbage sealed by the session is the player's own
+	if _, secure, err := server.openPacket(client.Seal([]byte("garbage")), player); err == nil || !secure {
+		t.Errorf("sealed garbage got %v and secure %v", err, secure)
+	}
+}
@@ -3,25 +3,52 @@ package gosnake
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"net"
+	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	splitChildPackageSize = 1350
-	splitChildPackageNum  = 10
 	serverReadBufferSize  = 512
+	minDatagramSize       = 548
+	splitChildPace        = time.Millisecond
+	// maxFrameSize caps the data of a frame, the snapshots of the rooms
+	// are validated against it. The children of a frame are capped for
+	// the smallest datagram size the client may negotiate.
+	maxFrameSize         = 160 * 1024
+	splitChildPackageNum = (maxFrameSize + minDatagramSize - childHeaderSize - 1) /
+		(minDatagramSize - childHeaderSize)
 )
 
 var DefaultServerOptions = &ServerOptions{
-	Addr:     "127.0.0.1:9001",
-	RoomSize: 5,
+	Addr:            "127.0.0.1:9001",
+	RoomSize:        5,
+	MaxRooms:        20,
+	MaxDatagramSize: splitChildPackageSize,
+	DrainSeconds:    10,
 	RoomOptions: &RoomOptions{
 		BorderWidth:        32,
 		BorderHeight:       32,
 		AutoMoveIntervalMS: 300,
+		TickIntervalMS:     50,
+		BoostCostSteps:     10,
 		PlayerSize:         5,
+		FoodNum:            1,
+		FoodPerPlayer:      1,
+		FoodSpawn:          FoodSpawnRandom,
+		GoldenFoodPercent:  10,
+		SpawnRunway:        5,
+		SpawnProtectionMS:  2000,
+		CollisionRule:      CollisionBothDie,
+		MaxClientBandwidth: 64 * 1024,
 	},
 }
 
@@ -31,27 +58,80 @@ func RunServer(ctx context.Context) error {
 }
 
 type ServerOptions struct {
-	Addr        string
-	RoomSize    int
-	RoomOptions *RoomOptions
+	Addr            string       `json:"listen_addr"`
+	RoomSize        int          `json:"room_size"`
+	MaxRooms        int          `json:"max_rooms"`
+	MaxDatagramSize int          `json:"max_datagram_size"`
+	RoomOptions     *RoomOptions `json:"room_options"`
+
+	// AdminAddr is the address of the admin API, it is disabled if empty.
+	// The requests need the admin token as a bearer token if it is set.
+	AdminAddr  string `json:"admin_addr"`
+	AdminToken string `json:"admin_token"`
+
+	// DrainSeconds is how long the players may finish their games once
+	// the server is shutting down. The stats of the server are appended
+	// to the stats file as a JSON line on shutdown if it is set.
+	DrainSeconds int    `json:"drain_seconds"`
+	StatsFile    string `json:"stats_file"`
+
+	// KeyFile keeps the key the handshakes are signed with, the clients
+	// pin its public key. It is created if missing, the key lasts for the
+	// run if the file is not set.
+	KeyFile string `json:"key_file"`
+
+	// Rooms lists the rooms started with the server, the rooms created
+	// by the players use the room options.
+	Rooms []*RoomOptions `json:"rooms"`
 }
 type Server struct {
 	options         ServerOptions
-	rooms           []*Room
+	rooms           map[int]*Room
 	splitDataSender *SplitDataSender
+	sessions        map[string]*SecureSession
+	sessionsMu      sync.Mutex
+	sendData        sendFunc
+	guard           *AbuseGuard
+	key             ed25519.PrivateKey
+	wg              sync.WaitGroup
+	packetsIn       uint64
+	bytesIn         uint64
+	draining        int32
+	startedAt       time.Time
+
+	// mu guards the rooms and the options changed by the reload, the ids
+	// of the rooms are never reused
+	mu         sync.RWMutex
+	ctx        context.Context
+	configured []*Room
+	nextRoomID int
+	// stoppedLimited keeps the limited commands of the stopped rooms
+	stoppedLimited uint64
 }
 
 func NewServer(options *ServerOptions) *Server {
 	return &Server{
 		options: *options,
 		splitDataSender: NewSplitDataSender(
-			splitChildPackageSize,
+			uint32(options.MaxDatagramSize), splitChildPace,
 		),
+		rooms:    make(map[int]*Room),
+		sessions: make(map[string]*SecureSession),
+		guard:    NewAbuseGuard(),
 	}
 }
 
 // Run run a sever
 func (s *Server) Run(ctx context.Context) error {
+	if err := s.options.Validate(); err != nil {
+		return err
+	}
+	key, err := LoadServerKey(s.options.KeyFile)
+	if err != nil {
+		return err
+	}
+	s.key = key
+
 	// resolve listen addr
 	listenAddr, err := net.ResolveUDPAddr("udp", s.options.Addr)
 	if err != nil {
@@ -65,51 +145,346 @@ func (s *Server) Run(ctx context.Context) error {
 	}
 	defer conn.Close()
 
-	sendData := func(data []byte, addr *net.UDPAddr) {
-		s.splitDataSender.SendDataWithUDP(data, conn, addr)
+	s.sendData = func(data []byte, addr *net.UDPAddr, datagramSize int) {
+		if session := s.getSession(addr); session != nil {
+			data = session.Seal(data)
+		}
+		s.splitDataSender.SendDataWithUDP(
+			data, conn, addr, uint32(datagramSize),
+		)
 	}
 
-	// create and run rooms
-	s.rooms = make([]*Room, s.options.RoomSize)
-	wg := &sync.WaitGroup{}
-	defer wg.Wait()
-	for i := 0; i < len(s.rooms); i++ {
-		room := NewRoom(s.options.RoomOptions, sendData)
-		s.rooms[i] = room
-		wg.Add(1)
-		go func(room *Room) {
-			room.Run(ctx)
-			wg.Done()
-		}(room)
+	s.startedAt = time.Now()
+
+	// create and run rooms, they keep running while the server drains
+	roomsCtx, stopRooms := context.WithCancel(context.Background())
+	defer s.wg.Wait()
+	defer stopRooms()
+	s.mu.Lock()
+	s.ctx = roomsCtx
+	for _, options := range s.options.GetRooms() {
+		s.configured = append(s.configured, s.startRoom(options, false))
+	}
+	roomNum, adminAddr := len(s.configured), s.options.AdminAddr
+	s.mu.Unlock()
+
+	if adminAddr != "" {
+		admin, err := s.startAdmin()
+		if err != nil {
+			return err
+		}
+		defer admin.Close()
 	}
+	logger(LogServer).Info(
+		"server started", "addr", conn.LocalAddr().String(),
+		"rooms", roomNum, "admin_addr", adminAddr, "server_key", EncodeServerKey(s.key),
+	)
 
 	// Recieve
+	readDone := make(chan struct{})
+	go func() {
+		s.readLoop(conn)
+		close(readDone)
+	}()
+
+	<-ctx.Done()
+	players, rooms := s.drain()
+
+	// unblock the read loop
+	conn.SetReadDeadline(time.Now())
+	<-readDone
+	logger(LogServer).Info("server stopped")
+	return s.writeStats(players, rooms)
+}
+
+// readLoop handles the packets until the deadline of the connection is
+// exceeded or the connection is closed.
+func (s *Server) readLoop(conn *net.UDPConn) {
 	buf := make([]byte, serverReadBufferSize)
 	for {
-		select {
-		case <-ctx.Done():
-			return nil
+		n, sender, err := conn.ReadFromUDP(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil || sender == nil || n <= 0 {
+			continue
+		}
+		atomic.AddUint64(&s.packetsIn, 1)
+		atomic.AddUint64(&s.bytesIn, uint64(n))
+		now := time.Now()
+		if !s.guard.Allow(sender, now) {
+			continue
+		}
+		cliData, secure, err := s.openPacket(buf[:n], sender)
+		switch err {
+		case nil:
+		case errReplayed, errNotOpened:
+			// the replayed packets may be duplicated by the network, and
+			// anyone may spoof the sealed packets of a player
+			continue
+		case errNoSession:
+			// the session is lost after a restart or an idle sweep, the
+			// repeats over the rate of the rehandshakes are malformed
+			if s.guard.AllowRehandshake(sender, now) {
+				s.sendRehandshake(sender, conn)
+			} else {
+				s.guard.Malformed(sender, now)
+			}
+			continue
 		default:
-			n, sender, err := conn.ReadFromUDP(buf)
-			if err != nil || sender == nil || n <= 0 {
-				continue
+			// the garbage is only held against the sender which proves
+			// the session, or which has none to be spoofed
+			if secure || s.getSession(sender) == nil {
+				s.guard.Malformed(sender, now)
 			}
-			cliData, err := s.decodeClientData(buf[:])
-			if err != nil || cliData.RoomID > len(s.rooms) {
-				continue
+			continue
+		}
+		s.handleClientData(cliData, secure, sender, conn)
+	}
+}
+
+func (s *Server) handleClientData(cliData *ClientData, secure bool, sender *net.UDPAddr, conn *net.UDPConn) {
+	// the commands below cost the server the most
+	switch cliData.CMD {
+	case CMDHandshake, CMDJoin, CMDCreateRoom:
+		if !s.guard.AllowJoin(sender, time.Now()) {
+			return
+		}
+	}
+
+	switch cliData.CMD {
+	case CMDHandshake:
+		s.handleHandshake(cliData, secure, sender, conn)
+		return
+	case CMDListRooms:
+		s.handleListRooms(sender)
+		return
+	}
+
+	// the sender with a session only sends sealed packets
+	if !secure && s.getSession(sender) != nil {
+		return
+	}
+
+	switch cliData.CMD {
+	case CMDCreateRoom:
+		s.handleCreateRoom(cliData, sender)
+		return
+	case CMDJoin:
+		size, err := s.negotiateDatagramSize(cliData.MaxDatagramSize)
+		if err != nil {
+			s.sendError(sender, minDatagramSize, err.Error())
+			return
+		}
+		cliData.MaxDatagramSize = size
+		if cliData.InviteCode != "" {
+			roomID, ok := s.findInviteCode(cliData.InviteCode)
+			if !ok {
+				s.sendError(sender, size, "invalid invite code")
+				return
 			}
-			room := s.rooms[cliData.RoomID]
-			room.HandleData(&RoomData{
-				Sender:     sender,
-				ClientData: cliData,
-			})
+			cliData.RoomID = roomID
 		}
 	}
+	room := s.getRoom(cliData.RoomID)
+	if room == nil {
+		return
+	}
+	ok := room.HandleData(&RoomData{
+		Sender:     sender,
+		ClientData: cliData,
+		Secure:     secure,
+	})
+	if !ok {
+		s.guard.Dropped()
+	}
+}
+
+// startRoom creates and runs a room with the options under a new id, the
+// room is forgotten once it stops. A room created by a player retires once
+// it is empty for createdRoomIdle. The caller holds the lock.
+func (s *Server) startRoom(options *RoomOptions, created bool) *Room {
+	room := NewRoom(s.nextRoomID, options, s.sendData)
+	s.nextRoomID++
+	if created {
+		room.created = true
+		room.idleRetire = createdRoomIdle
+	}
+	s.rooms[room.id] = room
+	s.wg.Add(1)
+	go func() {
+		room.Run(s.ctx)
+		s.removeRoom(room)
+		s.wg.Done()
+	}()
+	return room
+}
+
+func (s *Server) removeRoom(room *Room) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rooms, room.id)
+	s.stoppedLimited += room.GetLimitedCMDs()
+}
+
+// getRooms returns the running rooms ordered by their ids.
+func (s *Server) getRooms() []*Room {
+	s.mu.RLock()
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.mu.RUnlock()
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].id < rooms[j].id
+	})
+	return rooms
+}
+
+func (s *Server) getRoom(roomID int) *Room {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rooms[roomID]
+}
+
+// GetAbuseStats returns the counters of the packets refused by the server
+// and its rooms.
+func (s *Server) GetAbuseStats() AbuseStats {
+	stats := s.guard.GetStats()
+	s.mu.RLock()
+	stats.PlayerLimited += s.stoppedLimited
+	for _, room := range s.rooms {
+		stats.PlayerLimited += room.GetLimitedCMDs()
+	}
+	s.mu.RUnlock()
+	return stats
+}
+
+// sendError sends the error at the datagram size negotiated with the
+// sender, the minimum size is used before the negotiation.
+func (s *Server) sendError(addr *net.UDPAddr, datagramSize int, message string) {
+	data := &ServerData{
+		Error: &ErrorData{Message: message},
+	}
+	s.sendData(data.Encode(), addr, datagramSize)
+}
+
+// sendRehandshake tells the sender in plain that its session is lost, so
+// that the client handshakes again instead of being banned.
+func (s *Server) sendRehandshake(addr *net.UDPAddr, conn *net.UDPConn) {
+	s.splitDataSender.SendDataWithUDP(rehandshakeMarker, conn, addr, minDatagramSize)
 }
 
 type ClientData struct {
 	RoomID int
 	CMD    CMD
+	Seq    uint32
+
+	// the fields of the join and create room commands
+	MaxDatagramSize int
+	Password        string
+	InviteCode      string
+
+	// the field of the handshake command
+	PublicKey []byte
+
+	// the field of the chat command
+	Text string
+
+	// the fields of the ping command
+	ClientTime     int64
+	EchoServerTime int64
+	EchoDelay      int64
+}
+
+// negotiateDatagramSize returns the size of the datagrams sent to the
+// client, which is not larger than both of the client and the server. The
+// client unable to receive the minimum size is refused, the size of the
+// client which does not tell it is the one of the server.
+func (s *Server) negotiateDatagramSize(size int) (int, error) {
+	s.mu.RLock()
+	maxSize := s.options.MaxDatagramSize
+	s.mu.RUnlock()
+	if size <= 0 || size > maxSize {
+		return maxSize, nil
+	}
+	if size < minDatagramSize {
+		return 0, fmt.Errorf(
+			"datagram size %d is less than the minimum %d", size, minDatagramSize,
+		)
+	}
+	return size, nil
+}
+
+// openPacket decodes the client data of the packet, the sealed packet is
+// opened with the session of the sender at first. The secure is set once
+// the packet is opened, even if the data can not be decoded.
+func (s *Server) openPacket(packet []byte, sender *net.UDPAddr) (cliData *ClientData, secure bool, err error) {
+	if IsSealed(packet) {
+		session := s.getSession(sender)
+		if session == nil {
+			err = errNoSession
+			if len(packet) < minSealedSize {
+				err = errNotSealed
+			}
+			return
+		}
+		packet, err = session.Open(packet)
+		if err != nil {
+			if err != errReplayed {
+				err = errNotOpened
+			}
+			return
+		}
+		secure = true
+	}
+	cliData, err = s.decodeClientData(packet)
+	return
+}
+
+// handleHandshake creates the secure session of the sender, and replies
+// the public key of the server in plain, signed with the key of the server
+// so that no one in the middle can answer in place of it. A live session is only replaced
+// by a handshake sealed with it, so that a spoofed handshake can neither
+// take over nor break the session of the player.
+func (s *Server) handleHandshake(cliData *ClientData, secure bool, sender *net.UDPAddr, conn *net.UDPConn) {
+	if old := s.getSession(sender); old != nil && !secure && !old.IsIdle(time.Now()) {
+		logger(LogNetwork).Warn("handshake refused", "addr", sender.String())
+		return
+	}
+	priv, err := NewKeyPair()
+	if err != nil {
+		return
+	}
+	session, err := NewSecureSession(priv, cliData.PublicKey, true)
+	if err != nil {
+		return
+	}
+	s.sessionsMu.Lock()
+	now := time.Now()
+	for key, ss := range s.sessions {
+		if ss.IsIdle(now) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[sender.String()] = session
+	s.sessionsMu.Unlock()
+	serverPub := priv.PublicKey().Bytes()
+	reply := &ServerData{
+		Handshake: &HandshakeData{
+			PublicKey: serverPub,
+			Signature: signHandshake(s.key, cliData.PublicKey, serverPub),
+		},
+	}
+	s.splitDataSender.SendDataWithUDP(
+		reply.Encode(), conn, sender, minDatagramSize,
+	)
+}
+
+func (s *Server) getSession(addr *net.UDPAddr) *SecureSession {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	return s.sessions[addr.String()]
 }
 
 func (s *Server) decodeClientData(data []byte) (clientData *ClientData, err error) {
@@ -0,0 +1,175 @@
+package gosnake
+
+import "errors"
+
+var errUnknownKind = errors.New("unknown kind of message")
+
+// A message of the server starts with the kind of its payload, which is
+// encoded by the wireWriter, so that the control messages are a few bytes
+// and the snapshots do not carry the descriptions of their types.
+const (
+	kindScene byte = iota + 1
+	kindPong
+	kindJoin
+	kindHandshake
+	kindError
+	kindNotice
+	kindChat
+	kindRooms
+	kindRoomCreated
+	kindDeath
+)
+
+// ServerData is the message sent from the server to a client, only one
+// of the fields is set.
+type ServerData struct {
+	Scene *SceneData
+	Pong  *PongData
+	Join  *JoinData
+
+	Handshake *HandshakeData
+
+	Error       *ErrorData
+	Notice      *NoticeData
+	Chat        *ChatData
+	Rooms       []*RoomInfo
+	RoomCreated *RoomCreatedData
+
+	// Death is sent once to the player whose snake is dead.
+	Death *DeathData
+}
+
+// NoticeData is a message of the server operator shown to the players.
+type NoticeData struct {
+	Message string
+}
+
+// ErrorData tells the client why its request is rejected.
+type ErrorData struct {
+	Message string
+}
+
+// HandshakeData replies the handshake with the public key of the server,
+// it is signed with the key of the server along with the key of the client.
+type HandshakeData struct {
+	PublicKey []byte
+	Signature []byte
+}
+
+// JoinData acknowledges the join of the player with the negotiated size
+// of the datagrams, and the events the player has received.
+type JoinData struct {
+	PlayerID     string
+	RoomID       int
+	Private      bool
+	DatagramSize int
+	// EventSeq is the sequence of the last event the player received.
+	EventSeq uint32
+}
+
+type PongData struct {
+	ClientTime int64
+}
+
+func (sd *ServerData) Encode() []byte {
+	w := &wireWriter{}
+	switch {
+	case sd.Scene != nil:
+		w.Byte(kindScene)
+		sd.Scene.encode(w)
+	case sd.Pong != nil:
+		w.Byte(kindPong)
+		w.Int(sd.Pong.ClientTime)
+	case sd.Join != nil:
+		w.Byte(kindJoin)
+		sd.Join.encode(w)
+	case sd.Handshake != nil:
+		w.Byte(kindHandshake)
+		w.Bytes(sd.Handshake.PublicKey)
+		w.Bytes(sd.Handshake.Signature)
+	case sd.Error != nil:
+		w.Byte(kindError)
+		w.String(sd.Error.Message)
+	case sd.Notice != nil:
+		w.Byte(kindNotice)
+		w.String(sd.Notice.Message)
+	case sd.Chat != nil:
+		w.Byte(kindChat)
+		w.String(sd.Chat.PlayerID)
+		w.String(sd.Chat.Text)
+	case sd.Rooms != nil:
+		w.Byte(kindRooms)
+		w.Uint(uint64(len(sd.Rooms)))
+		for _, room := range sd.Rooms {
+			room.encode(w)
+		}
+	case sd.RoomCreated != nil:
+		w.Byte(kindRoomCreated)
+		w.Int(int64(sd.RoomCreated.RoomID))
+		w.String(sd.RoomCreated.InviteCode)
+	case sd.Death != nil:
+		w.Byte(kindDeath)
+		sd.Death.encode(w)
+	}
+	return w.buf
+}
+
+func DecodeServerData(data []byte) (*ServerData, error) {
+	r := &wireReader{data: data}
+	sd := &ServerData{}
+	switch r.Byte() {
+	case kindScene:
+		sd.Scene = &SceneData{}
+		sd.Scene.decode(r)
+	case kindPong:
+		sd.Pong = &PongData{ClientTime: r.Int()}
+	case kindJoin:
+		sd.Join = &JoinData{}
+		sd.Join.decode(r)
+	case kindHandshake:
+		sd.Handshake = &HandshakeData{PublicKey: r.Bytes(), Signature: r.Bytes()}
+	case kindError:
+		sd.Error = &ErrorData{Message: r.String()}
+	case kindNotice:
+		sd.Notice = &NoticeData{Message: r.String()}
+	case kindChat:
+		sd.Chat = &ChatData{PlayerID: r.String(), Text: r.String()}
+	case kindRooms:
+		sd.Rooms = make([]*RoomInfo, r.Len())
+		for i := range sd.Rooms {
+			sd.Rooms[i] = &RoomInfo{}
+			sd.Rooms[i].decode(r)
+		}
+	case kindRoomCreated:
+		sd.RoomCreated = &RoomCreatedData{
+			RoomID: int(r.Int()), InviteCode: r.String(),
+		}
+	case kindDeath:
+		sd.Death = &DeathData{}
+		sd.Death.decode(r)
+	default:
+		if r.err == nil {
+			r.err = errUnknownKind
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return sd, nil
+}
+
+func (jd *JoinData) encode(w *wireWriter) {
+	w.String(jd.PlayerID)
+	w.Int(int64(jd.RoomID))
+	w.Bool(jd.Private)
+	w.Uint(uint64(jd.DatagramSize))
+	w.Uint(uint64(jd.EventSeq))
+}
+
+func (jd *JoinData) decode(r *wireReader) {
+	jd.PlayerID = r.String()
+	jd.RoomID = int(r.Int())
+	jd.Private = r.Bool()
+	jd.DatagramSize = int(r.Uint())
+	jd.EventSeq = uint32(r.Uint())
+}
@@ -0,0 +1,67 @@
+package gosnake
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServerDataEncode(t *testing.T) {
+	scene := newTestScene(7, 3, DirRight, Position{4, 5}, Position{3, 5}, Position{3, 6})
+	scene.Food.AddPositions(map[Position]struct{}{{1, 1}: {}, {2, 2}: {}})
+	scene.GoldenFood = []Position{{2, 2}}
+	scene.PlayerStats = PlayerStats{{ID: "a", Score: 2, Boost: true, Kills: 1, LatencyMS: 40}}
+	scene.Seq, scene.ServerTime = 9, time.Now().UnixNano()
+	scene.Events = []*GameEvent{
+		{Seq: 1, Kind: EventJoin, PlayerID: "a"},
+		{Seq: 2, Kind: EventDeath, PlayerID: "b", OtherID: "a", Cause: DeathBody, Score: 3},
+	}
+	for _, c := range []struct {
+		name    string
+		data    *ServerData
+		maxSize int
+	}{
+		{"scene", &ServerData{Scene: scene}, 0},
+		{"pong", &ServerData{Pong: &PongData{ClientTime: time.Now().UnixNano()}}, 16},
+		{"join", &ServerData{Join: &JoinData{
+			PlayerID: "127.0.0.1:50000", RoomID: 2, Private: true, DatagramSize: 1350, EventSeq: 12,
+		}}, 32},
+		{"handshake", &ServerData{Handshake: &HandshakeData{
+			PublicKey: make([]byte, 32), Signature: make([]byte, 64),
+		}}, 104},
+		{"error", &ServerData{Error: &ErrorData{Message: "room is full"}}, 16},
+		{"notice", &ServerData{Notice: &NoticeData{Message: "restarting"}}, 16},
+		{"chat", &ServerData{Chat: &ChatData{PlayerID: "a", Text: "gg"}}, 8},
+		{"no rooms", &ServerData{Rooms: []*RoomInfo{}}, 4},
+		{"rooms", &ServerData{Rooms: []*RoomInfo{
+			{ID: 0, Players: 2, PlayerSize: 5}, {ID: 1, Private: true},
+		}}, 16},
+		{"room created", &ServerData{RoomCreated: &RoomCreatedData{RoomID: 5, InviteCode: "ABC234"}}, 16},
+		{"death", &ServerData{Death: &DeathData{
+			Cause: DeathBody, KillerID: "127.0.0.1:50000", Length: 3, Score: -1, Kills: 2, Survival: time.Second,
+		}}, 40},
+	} {
+		data := c.data.Encode()
+		if c.maxSize > 0 && len(data) > c.maxSize {
+			t.Errorf("%s: got %d bytes, want %d at most", c.name, len(data), c.maxSize)
+		}
+		got, err := DecodeServerData(data)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.data) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.data)
+		}
+		// the truncated messages are refused
+		for n := 0; n < len(data); n++ {
+			if _, err := DecodeServerData(data[:n]); err == nil {
+				t.Errorf("%s: truncated to %d bytes is decoded", c.name, n)
+				break
+			}
+		}
+	}
+	if _, err := DecodeServerData([]byte{0xee}); err != errUnknownKind {
+		t.Errorf("unknown kind got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package gosnake
+
+import "testing"
+
+func TestNegotiateDatagramSize(t *testing.T) {
+	options := DefaultServerOptions.clone()
+	options.MaxDatagramSize = 1200
+	server := NewServer(options)
+	for _, c := range []struct {
+		size int
+		want int
+		err  bool
+	}{
+		{0, 1200, false},
+		{-1, 1200, false},
+		{9000, 1200, false},
+		{1000, 1000, false},
+		{minDatagramSize, minDatagramSize, false},
+		{minDatagramSize - 1, 0, true},
+		{100, 0, true},
+	} {
+		got, err := server.negotiateDatagramSize(c.size)
+		if got != c.want || (err != nil) != c.err {
+			t.Errorf("negotiateDatagramSize(%d) = %d, %v, want %d and error %v", c.size, got, err, c.want, c.err)
+		}
+	}
+}
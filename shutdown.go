@@ -0,0 +1,140 @@
+package gosnake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	drainNoticeInterval = 5 * time.Second
+	shutdownReason      = "server is shutting down"
+)
+
+var errServerDraining = errors.New(shutdownReason)
+
+// ShutdownStats are appended to the stats file once the server stops.
+type ShutdownStats struct {
+	StartedAt time.Time       `json:"started_at"`
+	StoppedAt time.Time       `json:"stopped_at"`
+	Rooms     []*RoomStats    `json:"rooms"`
+	Players   []*PlayerStatus `json:"players"`
+	PacketsIn uint64          `json:"received_packets"`
+	BytesIn   uint64          `json:"received_bytes"`
+	Send      SendStats       `json:"send"`
+	Abuse     AbuseStats      `json:"abuse"`
+}
+
+// IsDraining reports whether the server is shutting down, the new rooms
+// and players are refused then.
+func (s *Server) IsDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// drain retires all the rooms so that no one joins them anymore, and
+// notifies the players until their games are over or the drain timeout
+// expires. The rooms still running are closed then, the final states of
+// the players and the drained rooms are returned.
+func (s *Server) drain() ([]*PlayerStatus, []*Room) {
+	atomic.StoreInt32(&s.draining, 1)
+	s.mu.RLock()
+	timeout := time.Duration(s.options.DrainSeconds) * time.Second
+	s.mu.RUnlock()
+	rooms := s.getRooms()
+	logger(LogServer).Info("server draining", "timeout", timeout, "rooms", len(rooms))
+
+	var players []*PlayerStatus
+	allDone := make(chan struct{})
+	go func() {
+		for _, room := range rooms {
+			<-room.Done()
+		}
+		close(allDone)
+	}()
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(drainNoticeInterval)
+	defer ticker.Stop()
+	for _, room := range rooms {
+		room.Retire()
+	}
+	for {
+		// the last states are kept since the players may leave any time
+		players = players[:0]
+		for _, room := range rooms {
+			if status, ok := room.GetStatus(); ok {
+				players = append(players, status.Players...)
+			}
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		message := fmt.Sprintf("server shutting down in %d seconds", int((remaining+time.Second-1)/time.Second))
+		for _, room := range rooms {
+			room.Broadcast(message)
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-allDone:
+			timer.Stop()
+			logger(LogServer).Info("server drained")
+			return players, rooms
+		case <-ticker.C:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+
+	closed := 0
+	for _, room := range rooms {
+		if room.Close(shutdownReason) {
+			closed++
+		}
+	}
+	<-allDone
+	logger(LogServer).Info("drain timed out", "closed_rooms", closed, "players", len(players))
+	return players, rooms
+}
+
+// writeStats appends the stats of the server to the stats file as a JSON
+// line if it is set, the rooms are stopped and forgotten by the server.
+func (s *Server) writeStats(players []*PlayerStatus, rooms []*Room) error {
+	s.mu.RLock()
+	path := s.options.StatsFile
+	s.mu.RUnlock()
+	if path == "" {
+		return nil
+	}
+	stats := &ShutdownStats{
+		StartedAt: s.startedAt,
+		StoppedAt: time.Now(),
+		Players:   players,
+		PacketsIn: atomic.LoadUint64(&s.packetsIn),
+		BytesIn:   atomic.LoadUint64(&s.bytesIn),
+		Send:      s.splitDataSender.GetStats(),
+		Abuse:     s.GetAbuseStats(),
+	}
+	for _, room := range rooms {
+		stats.Rooms = append(stats.Rooms, room.GetStats())
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("stats file %s: %w", path, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("stats file %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("stats file %s: %w", path, err)
+	}
+	logger(LogServer).Info("stats written", "file", path)
+	return nil
+}
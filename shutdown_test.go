@@ -0,0 +1,61 @@
+package gosnake
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServerShutdown(t *testing.T) {
+	options := DefaultServerOptions.clone()
+	options.Addr = "127.0.0.1:0"
+	options.RoomSize = 2
+	options.DrainSeconds = 30
+	options.StatsFile = filepath.Join(t.TempDir(), "stats.jsonl")
+	server := NewServer(options)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Run(ctx)
+	}()
+	for len(server.getRooms()) < options.RoomSize {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the empty rooms do not wait for the drain timeout
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+	if !server.IsDraining() {
+		t.Error("server is not draining")
+	}
+	if err := server.Reload(options); err != errServerDraining {
+		t.Errorf("got %v on reload, want %v", err, errServerDraining)
+	}
+
+	data, err := os.ReadFile(options.StatsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats ShutdownStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Rooms) != options.RoomSize {
+		t.Errorf("got %d rooms in the stats, want %d", len(stats.Rooms), options.RoomSize)
+	}
+	for _, rs := range stats.Rooms {
+		if !rs.Retired {
+			t.Errorf("room %d is not retired", rs.ID)
+		}
+	}
+}
@@ -1,7 +1,5 @@
 package gosnake
 
-import "math/rand"
-
 type Node struct {
 	next *Node
 	prev *Node
@@ -25,13 +23,6 @@ func (snake *Snake) GetTakes() map[Position]struct{} {
 	return takes
 }
 
-func NewCenterPosSnake(limit Limit) *Snake {
-	initPosX := (limit.MaxX - limit.MinX) / 2
-	initPosY := (limit.MaxY - limit.MinY) / 2
-	initDir := Direction(rand.Intn(4))
-	return NewSnake(initPosX, initPosY, initDir)
-}
-
 func NewSnake(initialPosX, initialPosY int, initialDir Direction) *Snake {
 	pos := Position{
 		X: initialPosX,
@@ -54,6 +45,33 @@ func NewSnake(initialPosX, initialPosY int, initialDir Direction) *Snake {
 	}
 }
 
+// NewBodySnake creates a snake with the body positions ordered from
+// head to tail.
+func NewBodySnake(body []Position, dir Direction) *Snake {
+	tail := body[len(body)-1]
+	snake := NewSnake(tail.X, tail.Y, dir)
+	for i := len(body) - 2; i >= 0; i-- {
+		node := &Node{
+			next: snake.head,
+			pos:  body[i],
+		}
+		snake.head.prev = node
+		snake.head = node
+		snake.length += 1
+		snake.takes[body[i]] = struct{}{}
+	}
+	return snake
+}
+
+// GetBody returns the positions of the snake from head to tail.
+func (s *Snake) GetBody() []Position {
+	body := make([]Position, 0, s.length)
+	for node := s.head; node != nil; node = node.next {
+		body = append(body, node.pos)
+	}
+	return body
+}
+
 func (s *Snake) GetDir() Direction {
 	return s.dir
 }
@@ -75,19 +93,7 @@ func (s *Snake) GetNextHeadPos(dir Direction) *Position {
 		return nil
 	}
 
-	pos := s.GetHeadPos()
-
-	switch dir {
-	case DirUp:
-		pos.Y -= 1
-	case DirRight:
-		pos.X += 1
-	case DirDown:
-		pos.Y += 1
-	case DirLeft:
-		pos.X -= 1
-	}
-
+	pos := s.GetHeadPos().Next(dir)
 	return &pos
 }
 
@@ -123,6 +129,20 @@ func (s *Snake) Grow() {
 	s.length += 1
 }
 
+// Shrink removes the tail of the snake, a snake is never shorter than
+// one node.
+func (s *Snake) Shrink() bool {
+	if s.length <= 1 {
+		return false
+	}
+	delete(s.takes, s.tail.pos)
+	s.prevTail = s.tail
+	s.tail = s.tail.prev
+	s.tail.next = nil
+	s.length -= 1
+	return true
+}
+
 func (s *Snake) IsTaken(pos Position) bool {
 	_, ok := s.takes[pos]
 	return ok
@@ -0,0 +1,90 @@
+package gosnake
+
+// PathRun is a run of Len nodes, each one lies to Dir of the previous one
+// when walking the snake from head to tail.
+type PathRun struct {
+	Dir Direction
+	Len uint16
+}
+
+// SnakeData describes a snake in the snapshot, the body is run length
+// encoded to keep the snapshot small.
+type SnakeData struct {
+	ID        string
+	Head      Position
+	Dir       Direction
+	Path      []PathRun
+	Protected bool
+	Over      bool
+}
+
+func NewSnakeData(id string, body []Position, dir Direction) *SnakeData {
+	sd := &SnakeData{
+		ID:   id,
+		Head: body[0],
+		Dir:  dir,
+	}
+	for i := 1; i < len(body); i++ {
+		d := getStepDir(body[i-1], body[i])
+		n := len(sd.Path)
+		if n > 0 && sd.Path[n-1].Dir == d {
+			sd.Path[n-1].Len += 1
+			continue
+		}
+		sd.Path = append(sd.Path, PathRun{Dir: d, Len: 1})
+	}
+	return sd
+}
+
+// GetBody decodes the positions of the snake from head to tail.
+func (sd *SnakeData) GetBody() []Position {
+	body := []Position{sd.Head}
+	pos := sd.Head
+	for _, run := range sd.Path {
+		for i := uint16(0); i < run.Len; i++ {
+			pos = pos.Next(run.Dir)
+			body = append(body, pos)
+		}
+	}
+	return body
+}
+
+func getStepDir(from, to Position) Direction {
+	switch {
+	case to.Y < from.Y:
+		return DirUp
+	case to.X > from.X:
+		return DirRight
+	case to.Y > from.Y:
+		return DirDown
+	default:
+		return DirLeft
+	}
+}
+
+func (sd *SnakeData) encode(w *wireWriter) {
+	w.String(sd.ID)
+	w.Position(sd.Head)
+	w.Uint(uint64(sd.Dir))
+	w.Uint(uint64(len(sd.Path)))
+	for _, run := range sd.Path {
+		w.Uint(uint64(run.Dir))
+		w.Uint(uint64(run.Len))
+	}
+	w.Bool(sd.Protected)
+	w.Bool(sd.Over)
+}
+
+func (sd *SnakeData) decode(r *wireReader) {
+	sd.ID = r.String()
+	sd.Head = r.Position()
+	sd.Dir = Direction(r.Uint())
+	if n := r.Len(); n > 0 {
+		sd.Path = make([]PathRun, n)
+		for i := range sd.Path {
+			sd.Path[i] = PathRun{Dir: Direction(r.Uint()), Len: uint16(r.Uint())}
+		}
+	}
+	sd.Protected = r.Bool()
+	sd.Over = r.Bool()
+}
@@ -0,0 +1,20 @@
+package gosnake
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnakeDataBody(t *testing.T) {
+	body := []Position{
+		{X: 3, Y: 3}, {X: 3, Y: 4}, {X: 3, Y: 5}, {X: 4, Y: 5},
+		{X: 5, Y: 5}, {X: 6, Y: 5}, {X: 6, Y: 4},
+	}
+	sd := NewSnakeData("a", body, DirUp)
+	if len(sd.Path) != 3 {
+		t.Errorf("got %d path runs, want 3", len(sd.Path))
+	}
+	if got := sd.GetBody(); !reflect.DeepEqual(got, body) {
+		t.Errorf("got body %v, want %v", got, body)
+	}
+}
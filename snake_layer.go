@@ -0,0 +1,54 @@
+package gosnake
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// defaultSnakeColor is used when no snake color is configured.
+const defaultSnakeColor = "41"
+
+var headArrows = map[Direction]string{
+	DirUp:    "/\\",
+	DirRight: "=>",
+	DirDown:  "\\/",
+	DirLeft:  "<=",
+}
+
+// SnakeLayer renders every snake with its own color and an arrow head
+// which points to the direction of the snake.
+type SnakeLayer struct {
+	symbols map[Position]string
+}
+
+func NewSnakeLayer() *SnakeLayer {
+	return &SnakeLayer{
+		symbols: make(map[Position]string),
+	}
+}
+
+// AddSnake adds the snake with the background color, the body of a
+// protected snake is rendered dimmed.
+func (sl *SnakeLayer) AddSnake(sd *SnakeData, color string) {
+	style := IfStr(sd.Protected, "2", "1")
+	body := IfStr(sd.Protected, "::", "[]")
+	for i, pos := range sd.GetBody() {
+		text := IfStr(i == 0, headArrows[sd.Dir], body)
+		sl.symbols[pos] = fmt.Sprintf("\033[%s;%s;37m%s\033[0m", color, style, text)
+	}
+}
+
+func (sl *SnakeLayer) GetSymbolAt(pos Position) string {
+	return sl.symbols[pos]
+}
+
+// GetSnakeColor picks a stable color for the snake id from colors, the
+// default color is returned if colors is empty.
+func GetSnakeColor(id string, colors []string) string {
+	if len(colors) == 0 {
+		return defaultSnakeColor
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return colors[h.Sum32()%uint32(len(colors))]
+}
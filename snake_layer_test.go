@@ -0,0 +1,32 @@
+package gosnake
+
+import "testing"
+
+func TestGetSnakeColor(t *testing.T) {
+	colors := []string{"41", "43", "45"}
+	for _, c := range []struct {
+		name   string
+		id     string
+		colors []string
+	}{
+		{"no colors", "127.0.0.1:1", nil},
+		{"one color", "127.0.0.1:1", []string{"44"}},
+		{"colors", "127.0.0.1:1", colors},
+		{"empty id", "", colors},
+	} {
+		got := GetSnakeColor(c.id, c.colors)
+		if len(c.colors) == 0 && got != defaultSnakeColor {
+			t.Errorf("%s: got color %q, want the default", c.name, got)
+		}
+		found := len(c.colors) == 0
+		for _, color := range c.colors {
+			found = found || color == got
+		}
+		if !found {
+			t.Errorf("%s: got color %q out of %q", c.name, got, c.colors)
+		}
+		if again := GetSnakeColor(c.id, c.colors); again != got {
+			t.Errorf("%s: got color %q then %q", c.name, got, again)
+		}
+	}
+}
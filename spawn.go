@@ -0,0 +1,63 @@
+package gosnake
+
+import (
+	"errors"
+	"math/rand"
+)
+
+var errNoSpawnPos = errors.New("no free position to spawn")
+
+var directions = []Direction{DirUp, DirRight, DirDown, DirLeft}
+
+type spawnCandidate struct {
+	pos Position
+	dir Direction
+}
+
+// FindSpawn chooses a free position and a direction which has the longest
+// clear runway up to runway cells. The spawn points are preferred when any
+// of them is free, otherwise every free position inside limit is considered.
+func FindSpawn(limit Limit, points []Position, occupied Taker, runway int) (pos Position, dir Direction, err error) {
+	var free []Position
+	for _, point := range points {
+		if limit.Contains(point) && !occupied.IsTaken(point) {
+			free = append(free, point)
+		}
+	}
+	if len(free) == 0 {
+		free = GetFreePositions(limit, occupied)
+	}
+	if len(free) == 0 {
+		err = errNoSpawnPos
+		return
+	}
+
+	best := -1
+	var candidates []spawnCandidate
+	for _, pos := range free {
+		for _, dir := range directions {
+			n := getRunway(limit, occupied, pos, dir, runway)
+			if n < best {
+				continue
+			}
+			if n > best {
+				best = n
+				candidates = candidates[:0]
+			}
+			candidates = append(candidates, spawnCandidate{pos, dir})
+		}
+	}
+	candidate := candidates[rand.Intn(len(candidates))]
+	return candidate.pos, candidate.dir, nil
+}
+
+func getRunway(limit Limit, occupied Taker, pos Position, dir Direction, max int) (n int) {
+	for n < max {
+		pos = pos.Next(dir)
+		if !limit.Contains(pos) || occupied.IsTaken(pos) {
+			return
+		}
+		n++
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package gosnake
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFindSpawn(t *testing.T) {
+	// a row of five cells
+	row := Limit{MinX: 1, MaxX: 5, MinY: 1, MaxY: 1}
+	square := Limit{MinX: 1, MaxX: 8, MinY: 1, MaxY: 8}
+	right := DirRight
+	for _, c := range []struct {
+		name     string
+		limit    Limit
+		points   []Position
+		occupied []Position
+		runway   int
+		pos      *Position
+		dir      *Direction
+		want     int
+		err      error
+	}{
+		{"spawn point", square, []Position{{2, 2}}, nil, 3, &Position{2, 2}, nil, 3, nil},
+		{"taken spawn point", row, []Position{{1, 1}}, []Position{{1, 1}}, 3, nil, nil, 3, nil},
+		{"spawn point out of the limit", row, []Position{{0, 0}}, nil, 3, nil, nil, 3, nil},
+		{"longest runway", row, []Position{{1, 1}}, nil, 3, &Position{1, 1}, &right, 3, nil},
+		{"blocked runway", row, []Position{{1, 1}}, []Position{{3, 1}}, 3, &Position{1, 1}, &right, 1, nil},
+		{"no runway", row, []Position{{1, 1}}, []Position{{2, 1}, {4, 1}}, 3, &Position{1, 1}, nil, 0, nil},
+		{"full board", row, nil, []Position{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}}, 3, nil, nil, 0, errNoSpawnPos},
+	} {
+		occupied := NewCompressLayer(10, 10)
+		for _, pos := range c.occupied {
+			occupied.AddPositions(map[Position]struct{}{pos: {}})
+		}
+		pos, dir, err := FindSpawn(c.limit, c.points, occupied, c.runway)
+		if err != c.err {
+			t.Errorf("%s: got error %v, want %v", c.name, err, c.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !c.limit.Contains(pos) || occupied.IsTaken(pos) {
+			t.Errorf("%s: got taken position %v", c.name, pos)
+		}
+		if c.pos != nil && pos != *c.pos {
+			t.Errorf("%s: got position %v, want %v", c.name, pos, *c.pos)
+		}
+		if c.dir != nil && dir != *c.dir {
+			t.Errorf("%s: got direction %v, want %v", c.name, dir, *c.dir)
+		}
+		if got := getRunway(c.limit, occupied, pos, dir, c.runway); got != c.want {
+			t.Errorf("%s: got runway %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSpawnProtection(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		protectionMS int
+		elapsed      time.Duration
+		want         bool
+	}{
+		{"protected", 2000, 0, true},
+		{"expired", 2000, 2 * time.Second, false},
+		{"no protection", 0, 0, false},
+	} {
+		options := DefaultServerOptions.RoomOptions.clone()
+		options.SpawnProtectionMS = c.protectionMS
+		room := NewRoom(0, options, func([]byte, *net.UDPAddr, int) {})
+		room.Init()
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+		room.handleData(&RoomData{Sender: addr, ClientData: &ClientData{CMD: CMDJoin}})
+		player := room.players[addr.String()]
+		player.protectedUntil = player.protectedUntil.Add(-c.elapsed)
+		if got := player.IsProtected(); got != c.want {
+			t.Errorf("%s: got protected %v, want %v", c.name, got, c.want)
+		}
+		if got := player.GetSnakeData().Protected; got != c.want {
+			t.Errorf("%s: got protected %v in the snapshot, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package gosnake
+
+import "time"
+
+// TokenBucket allows rate tokens per second with bursts up to burst
+// tokens.
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate, burst float64) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+// Allow takes n tokens from the bucket, it reports false and takes nothing
+// if there are not enough tokens.
+func (tb *TokenBucket) Allow(now time.Time, n float64) bool {
+	if !tb.last.IsZero() {
+		tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+	if tb.tokens < n {
+		return false
+	}
+	tb.tokens -= n
+	return true
+}
@@ -0,0 +1,30 @@
+package gosnake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	for _, c := range []struct {
+		name  string
+		rate  float64
+		burst float64
+		takes []float64
+		after []time.Duration
+		want  []bool
+	}{
+		{"burst", 1, 3, []float64{1, 1, 1, 1}, []time.Duration{0, 0, 0, 0}, []bool{true, true, true, false}},
+		{"refill", 2, 2, []float64{2, 1, 1, 1}, []time.Duration{0, 500 * time.Millisecond, 500 * time.Millisecond, time.Second}, []bool{true, true, false, true}},
+		{"capped", 10, 2, []float64{2, 3, 2}, []time.Duration{0, time.Hour, time.Hour}, []bool{true, false, true}},
+		{"refused takes nothing", 1, 5, []float64{6, 5}, []time.Duration{0, 0}, []bool{false, true}},
+	} {
+		tb := NewTokenBucket(c.rate, c.burst)
+		for i, n := range c.takes {
+			if got := tb.Allow(start.Add(c.after[i]), n); got != c.want[i] {
+				t.Errorf("%s: take %d got %v, want %v", c.name, i, got, c.want[i])
+			}
+		}
+	}
+}
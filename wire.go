@@ -0,0 +1,125 @@
+package gosnake
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errShortMessage = errors.New("message is too short")
+
+// wireWriter appends the values in a compact binary encoding, the integers
+// are varints, the strings and the bytes are prefixed by their length.
+type wireWriter struct {
+	buf []byte
+}
+
+func (w *wireWriter) Byte(v byte) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *wireWriter) Bool(v bool) {
+	if v {
+		w.Byte(1)
+		return
+	}
+	w.Byte(0)
+}
+
+func (w *wireWriter) Uint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *wireWriter) Int(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *wireWriter) String(v string) {
+	w.Uint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *wireWriter) Bytes(v []byte) {
+	w.Uint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *wireWriter) Position(pos Position) {
+	w.Int(int64(pos.X))
+	w.Int(int64(pos.Y))
+}
+
+// wireReader reads the values written by the wireWriter, the first error
+// is kept and the values read after it are zero.
+type wireReader struct {
+	data []byte
+	err  error
+}
+
+func (r *wireReader) Byte() byte {
+	if r.err != nil || len(r.data) == 0 {
+		r.err = errShortMessage
+		return 0
+	}
+	v := r.data[0]
+	r.data = r.data[1:]
+	return v
+}
+
+func (r *wireReader) Bool() bool {
+	return r.Byte() != 0
+}
+
+func (r *wireReader) Uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errShortMessage
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *wireReader) Int() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errShortMessage
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// Len reads the length of a list or bytes, it can not exceed the data left
+// since every element takes a byte at least.
+func (r *wireReader) Len() int {
+	n := r.Uint()
+	if n > uint64(len(r.data)) {
+		r.err = errShortMessage
+		return 0
+	}
+	return int(n)
+}
+
+func (r *wireReader) String() string {
+	return string(r.Bytes())
+}
+
+func (r *wireReader) Bytes() []byte {
+	n := r.Len()
+	if r.err != nil {
+		return nil
+	}
+	v := append([]byte(nil), r.data[:n]...)
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *wireReader) Position() Position {
+	return Position{X: int(r.Int()), Y: int(r.Int())}
+}